@@ -303,10 +303,28 @@ func (d *AllocatableDevice) IsHealthy() bool {
 		// TODO: review -- what about the parent?
 		return d.MigStatic.health == Healthy
 	case MigDynamicDeviceType:
-		// TODOMIG: For now, pretend health -- this device maybe hasn't
-		// manifested yet. Or has it? We could adopt the health status of the
-		// parent, but that's also not meaningful I think.
+		// This device may not have manifested yet: adopt the health status of
+		// the parent (health events for manifested dynamic MIG devices are
+		// attributed to the parent, see nvmlDeviceHealthMonitor).
+		return d.MigDynamic.Parent.health == Healthy
+	case VfioDeviceType:
 		return true
 	}
 	panic("unexpected type for AllocatableDevice")
 }
+
+// IsDegraded returns true if a health event degraded the device (see
+// DeviceState.SetDeviceDegraded()).
+func (d *AllocatableDevice) IsDegraded() bool {
+	switch d.Type() {
+	case HAMiGpuDeviceType:
+		return d.HAMiGpu.degraded != ""
+	case GpuDeviceType:
+		return d.Gpu.degraded != ""
+	case MigStaticDeviceType:
+		return d.MigStatic.degraded != ""
+	case MigDynamicDeviceType:
+		return d.MigDynamic.Parent.degraded != ""
+	}
+	return false
+}
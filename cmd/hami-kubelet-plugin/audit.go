@@ -0,0 +1,213 @@
+/*
+Copyright 2025 The HAMi Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+)
+
+// The audit log records every mutation of a device on the host (MIG devices
+// and MIG mode, compute mode, time slice, vfio driver binding, HAMi-core
+// cache directories) as a JSON line, attributed to the claim it was done for
+// (if any). It is append-only, and rotated by size.
+
+// Audited operations.
+const (
+	auditOpCreateMigDevice          = "CreateMigDevice"
+	auditOpDeleteMigDevice          = "DeleteMigDevice"
+	auditOpSetMigMode               = "SetMigMode"
+	auditOpSetComputeMode           = "SetComputeMode"
+	auditOpSetTimeSlice             = "SetTimeSlice"
+	auditOpChangeDriver             = "ChangeDriver"
+	auditOpCreateHAMiCacheDirectory = "CreateHAMiCacheDirectory"
+	auditOpDeleteHAMiCacheDirectory = "DeleteHAMiCacheDirectory"
+)
+
+const (
+	auditResultSuccess = "Success"
+	auditResultFailure = "Failure"
+)
+
+type AuditRecord struct {
+	Time      time.Time `json:"time"`
+	Node      string    `json:"node"`
+	Operation string    `json:"operation"`
+	ClaimUID  string    `json:"claimUID,omitempty"`
+	// The GPU (UUID), PCI device (bus ID) or path mutated.
+	Target string `json:"target"`
+	// State of the target before and after the mutation, if known.
+	Before          string  `json:"before,omitempty"`
+	After           string  `json:"after,omitempty"`
+	Result          string  `json:"result"`
+	Error           string  `json:"error,omitempty"`
+	DurationSeconds float64 `json:"durationSeconds"`
+}
+
+type auditLogger struct {
+	sync.Mutex
+	path       string
+	node       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+// openAuditLog opens (appending to) the configured audit log. Returns nil if
+// no audit log path is configured.
+func openAuditLog(config *Config) (*auditLogger, error) {
+	path := config.flags.auditLogPath
+	if path == "" {
+		return nil, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return nil, fmt.Errorf("error creating audit log directory: %w", err)
+	}
+	l := &auditLogger{
+		path:       path,
+		node:       config.flags.nodeName,
+		maxSize:    int64(config.flags.auditLogMaxSizeMB) << 20,
+		maxBackups: config.flags.auditLogMaxBackups,
+	}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	klog.Infof("Writing audit log to %s", path)
+	return l, nil
+}
+
+func (l *auditLogger) open() error {
+	file, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("error opening audit log: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("error getting size of audit log: %w", err)
+	}
+	l.file = file
+	l.size = info.Size()
+	return nil
+}
+
+// rotate moves the audit log to <path>.1 (shifting previous backups, and
+// dropping the oldest one), and opens a new one.
+func (l *auditLogger) rotate() error {
+	err := l.file.Close()
+	l.file = nil
+	if err != nil {
+		return fmt.Errorf("error closing audit log: %w", err)
+	}
+	for i := l.maxBackups - 1; i >= 1; i-- {
+		err := os.Rename(fmt.Sprintf("%s.%d", l.path, i), fmt.Sprintf("%s.%d", l.path, i+1))
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("error rotating audit log: %w", err)
+		}
+	}
+	if l.maxBackups > 0 {
+		err = os.Rename(l.path, l.path+".1")
+	} else {
+		err = os.Remove(l.path)
+	}
+	if err != nil {
+		return fmt.Errorf("error rotating audit log: %w", err)
+	}
+	return l.open()
+}
+
+func (l *auditLogger) write(record *AuditRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("error marshaling audit record: %w", err)
+	}
+	line = append(line, '\n')
+
+	l.Lock()
+	defer l.Unlock()
+	if l.maxSize > 0 && l.size > 0 && l.size+int64(len(line)) > l.maxSize {
+		if err := l.rotate(); err != nil {
+			// Keep on appending to the current file.
+			klog.Errorf("Unable to rotate audit log: %v", err)
+		}
+	}
+	if l.file == nil {
+		if err := l.open(); err != nil {
+			return err
+		}
+	}
+	n, err := l.file.Write(line)
+	l.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("error writing audit record: %w", err)
+	}
+	return nil
+}
+
+func (l *auditLogger) Close() {
+	if l == nil {
+		return
+	}
+	l.Lock()
+	defer l.Unlock()
+	if l.file == nil {
+		return
+	}
+	if err := l.file.Close(); err != nil {
+		klog.Errorf("Unable to close audit log: %v", err)
+	}
+	l.file = nil
+}
+
+// mutation records a mutation of target started at t0, attributed to the
+// claim UID of the context (see withClaimUID()), if any. Failure to write the
+// record is logged only: it must not fail the operation. A noop on a nil
+// logger (no audit log configured).
+func (l *auditLogger) mutation(ctx context.Context, operation, target, before, after string, t0 time.Time, err error) {
+	if l == nil {
+		return
+	}
+	record := &AuditRecord{
+		Time:            t0.UTC(),
+		Node:            l.node,
+		Operation:       operation,
+		Target:          target,
+		Before:          before,
+		After:           after,
+		Result:          auditResultSuccess,
+		DurationSeconds: time.Since(t0).Seconds(),
+	}
+	if uid, ok := ctx.Value(claimUIDKey{}).(types.UID); ok {
+		record.ClaimUID = string(uid)
+	}
+	if err != nil {
+		record.Result = auditResultFailure
+		record.Error = err.Error()
+	}
+	if werr := l.write(record); werr != nil {
+		klog.Errorf("Unable to record %s of %s in audit log: %v", operation, target, werr)
+	}
+}
@@ -0,0 +1,127 @@
+/*
+Copyright 2025 The HAMi Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestAuditLoggerRotate(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		maxBackups int
+		rotations  int
+		// Content of the backups after the rotations, newest first.
+		expected []string
+	}{
+		{name: "no backups", maxBackups: 0, rotations: 2, expected: nil},
+		{name: "one backup", maxBackups: 1, rotations: 2, expected: []string{"1"}},
+		{name: "backups not all used", maxBackups: 3, rotations: 2, expected: []string{"1", "0"}},
+		{name: "oldest backup dropped", maxBackups: 2, rotations: 4, expected: []string{"3", "2"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			l := &auditLogger{path: filepath.Join(t.TempDir(), "audit.log"), maxBackups: tc.maxBackups}
+			require.NoError(t, l.open())
+			defer l.Close()
+
+			for i := 0; i < tc.rotations; i++ {
+				_, err := fmt.Fprint(l.file, i)
+				require.NoError(t, err)
+				require.NoError(t, l.rotate())
+				require.Zero(t, l.size)
+			}
+
+			var backups []string
+			for i := 1; i <= tc.maxBackups+1; i++ {
+				data, err := os.ReadFile(fmt.Sprintf("%s.%d", l.path, i))
+				if os.IsNotExist(err) {
+					break
+				}
+				require.NoError(t, err)
+				backups = append(backups, string(data))
+			}
+			require.Equal(t, tc.expected, backups)
+
+			data, err := os.ReadFile(l.path)
+			require.NoError(t, err)
+			require.Empty(t, data)
+		})
+	}
+}
+
+func TestAuditLoggerWriteRotates(t *testing.T) {
+	l := &auditLogger{path: filepath.Join(t.TempDir(), "audit.log"), maxSize: 200, maxBackups: 1}
+	require.NoError(t, l.open())
+	defer l.Close()
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, l.write(&AuditRecord{Operation: auditOpCreateMigDevice, Target: fmt.Sprintf("GPU-%d", i), Result: auditResultSuccess}))
+	}
+
+	current, err := os.ReadFile(l.path)
+	require.NoError(t, err)
+	backup, err := os.ReadFile(l.path + ".1")
+	require.NoError(t, err)
+	for _, data := range [][]byte{current, backup} {
+		require.LessOrEqual(t, len(data), 200)
+		require.True(t, strings.HasSuffix(string(data), "\n"))
+	}
+	require.Contains(t, string(current), "GPU-4")
+}
+
+func TestAuditLoggerMutation(t *testing.T) {
+	// No audit log configured.
+	var none *auditLogger
+	none.mutation(context.Background(), auditOpSetComputeMode, "GPU-0", "DEFAULT", "EXCLUSIVE_PROCESS", time.Now(), nil)
+
+	l := &auditLogger{path: filepath.Join(t.TempDir(), "audit.log"), node: "node-0"}
+	require.NoError(t, l.open())
+	defer l.Close()
+
+	ctx := withClaimUID(context.Background(), types.UID("claim-0"))
+	l.mutation(ctx, auditOpCreateMigDevice, "GPU-0", "", "1g.10gb", time.Now(), nil)
+	l.mutation(context.Background(), auditOpDeleteMigDevice, "GPU-0", "1g.10gb", "", time.Now(), errors.New("in use"))
+
+	data, err := os.ReadFile(l.path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	require.Len(t, lines, 2)
+
+	var records []AuditRecord
+	for _, line := range lines {
+		var r AuditRecord
+		require.NoError(t, json.Unmarshal([]byte(line), &r))
+		records = append(records, r)
+	}
+	require.Equal(t, "node-0", records[0].Node)
+	require.Equal(t, "claim-0", records[0].ClaimUID)
+	require.Equal(t, auditResultSuccess, records[0].Result)
+	require.Empty(t, records[1].ClaimUID)
+	require.Equal(t, auditResultFailure, records[1].Result)
+	require.Equal(t, "in use", records[1].Error)
+}
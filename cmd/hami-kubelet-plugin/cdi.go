@@ -17,10 +17,12 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -31,6 +33,7 @@ import (
 	"github.com/NVIDIA/nvidia-container-toolkit/pkg/nvcdi/spec"
 	transformroot "github.com/NVIDIA/nvidia-container-toolkit/pkg/nvcdi/transform/root"
 	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
 
 	utilcache "k8s.io/apimachinery/pkg/util/cache"
 	cdiapi "tags.cncf.io/container-device-interface/pkg/cdi"
@@ -46,6 +49,8 @@ const (
 	cdiClaimClass  = "claim"
 	defaultCDIRoot = "/var/run/cdi"
 	procNvCapsPath = "/proc/driver/nvidia/capabilities"
+
+	CDISpecGarbageCollectionInterval = 10 * time.Minute
 )
 
 type CDIHandler struct {
@@ -61,6 +66,7 @@ type CDIHandler struct {
 	specCache *utilcache.Expiring
 
 	cdiRoot string
+	dryRun  bool
 }
 
 func NewCDIHandler(opts ...cdiOption) (*CDIHandler, error) {
@@ -119,7 +125,13 @@ func (cdi *CDIHandler) writeSpec(spec spec.Interface, specName string) error {
 		return fmt.Errorf("failed to transform driver root in CDI spec: %w", err)
 	}
 
-	klog.V(7).Infof("Write CDI spec: %s", specName)
+	if cdi.dryRun {
+		if data, err := yaml.Marshal(spec.Raw()); err == nil {
+			klog.InfoS("Dry-run: CDI spec", "spec", specName, "content", string(data))
+		}
+	}
+
+	klog.V(7).InfoS("Write CDI spec", "spec", specName)
 	return spec.Save(filepath.Join(cdi.cdiRoot, specName+".yaml"))
 }
 
@@ -153,7 +165,7 @@ func (cdi *CDIHandler) WarmupDevSpecCache(uuids []string) {
 	for _, uuid := range uuids {
 		_, err := cdi.GetDeviceSpecsByUUIDCached(uuid)
 		if err != nil {
-			klog.Warningf("Ignore error during cache warmup: GetDeviceSpecsByUUIDCached() failed: %s", err)
+			klog.ErrorS(err, "Ignore error during cache warmup: GetDeviceSpecsByUUIDCached() failed", logKeyGPUUUID, uuid)
 		}
 	}
 }
@@ -172,7 +184,7 @@ func (cdi *CDIHandler) GetDeviceSpecsByUUIDCached(uuid string) ([]cdispec.Device
 
 	t0 := time.Now()
 	devs, err := cdi.nvcdiClaim.GetDeviceSpecsByID(uuid)
-	klog.V(1).Infof("GetDeviceSpecsByID() called for %s, t_cdi_get_device_specs_by_id %.3f s", uuid, time.Since(t0).Seconds())
+	klog.V(1).InfoS("GetDeviceSpecsByID() called", logKeyGPUUUID, uuid, logKeyDuration, time.Since(t0).Seconds())
 	if err != nil {
 		return nil, err
 	}
@@ -267,12 +279,17 @@ func (cdi *CDIHandler) CreateClaimSpecFile(claimUID string, preparedDevices Prep
 				}
 				dspec = dspecsmig[0]
 
-				devnodesForMig, err := cdi.GetDevNodesForMigDevice(dev.Mig.Concrete)
-				if err != nil {
-					return fmt.Errorf("failed to construct MIG device DeviceNode edits: %w", err)
+				if isDryRunMigDevice(dev.Mig.Concrete) {
+					// Not created: there are no capability device nodes.
+					klog.InfoS("Dry-run: CDI spec lacks MIG device nodes", logKeyClaimUID, claimUID, logKeyDevice, dev.CanonicalName())
+				} else {
+					devnodesForMig, err := cdi.GetDevNodesForMigDevice(dev.Mig.Concrete)
+					if err != nil {
+						return fmt.Errorf("failed to construct MIG device DeviceNode edits: %w", err)
+					}
+					klog.V(7).Infof("CDI spec: appending MIG device nodes")
+					dspec.ContainerEdits.DeviceNodes = append(dspec.ContainerEdits.DeviceNodes, devnodesForMig...)
 				}
-				klog.V(7).Infof("CDI spec: appending MIG device nodes")
-				dspec.ContainerEdits.DeviceNodes = append(dspec.ContainerEdits.DeviceNodes, devnodesForMig...)
 			}
 
 			// Associate thew newly generated spec with the claim-specific
@@ -288,7 +305,7 @@ func (cdi *CDIHandler) CreateClaimSpecFile(claimUID string, preparedDevices Prep
 				deviceEdits = deviceEdits.Append(group.ConfigState.containerEdits)
 				dspec.ContainerEdits = *deviceEdits.ContainerEdits
 			}
-			klog.V(7).Infof("Number of device nodes about to inject for device %s: %d", dname, len(dspec.ContainerEdits.DeviceNodes))
+			klog.V(7).InfoS("About to inject device nodes", logKeyClaimUID, claimUID, logKeyDevice, dev.CanonicalName(), "deviceNodes", len(dspec.ContainerEdits.DeviceNodes))
 			deviceSpecs = append(deviceSpecs, dspec)
 		}
 	}
@@ -308,7 +325,7 @@ func (cdi *CDIHandler) CreateClaimSpecFile(claimUID string, preparedDevices Prep
 	// it is bound to a DRA ResourceClaim, it's transient (bound to the lifetime
 	// of a container). Hence, Use the "transient spec" concept from CDI.
 	specName := cdiapi.GenerateTransientSpecName(cdiVendor, cdiClaimClass, claimUID)
-	klog.V(6).Infof("Writing CDI spec '%s' for claim '%s'", specName, claimUID)
+	klog.V(6).InfoS("Writing CDI spec", "spec", specName, logKeyClaimUID, claimUID)
 	result := cdi.writeSpec(spec, specName)
 
 	klog.V(7).Infof("t_gen_write_cdi_spec %.3f s", time.Since(tws0).Seconds())
@@ -317,7 +334,7 @@ func (cdi *CDIHandler) CreateClaimSpecFile(claimUID string, preparedDevices Prep
 
 func (cdi *CDIHandler) DeleteClaimSpecFile(claimUID string) error {
 	specName := cdiapi.GenerateTransientSpecName(cdiVendor, cdiClaimClass, claimUID)
-	klog.V(6).Infof("Delete CDI spec file: '%s', claim '%s'", specName, claimUID)
+	klog.V(6).InfoS("Delete CDI spec file", "spec", specName, logKeyClaimUID, claimUID)
 	err := os.Remove(filepath.Join(cdi.cdiRoot, specName+".yaml"))
 	if err != nil && !os.IsNotExist(err) {
 		return err
@@ -325,6 +342,78 @@ func (cdi *CDIHandler) DeleteClaimSpecFile(claimUID string) error {
 	return nil
 }
 
+// ListClaimSpecFileUIDs() returns the claim UIDs of all transient claim spec
+// files in the CDI root directory that were written by this driver.
+func (cdi *CDIHandler) ListClaimSpecFileUIDs() ([]string, error) {
+	entries, err := os.ReadDir(cdi.cdiRoot)
+	if err != nil {
+		return nil, fmt.Errorf("error reading CDI root %s: %w", cdi.cdiRoot, err)
+	}
+
+	prefix := cdiapi.GenerateTransientSpecName(cdiVendor, cdiClaimClass, "")
+	var uids []string
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		name, found := strings.CutSuffix(e.Name(), ".yaml")
+		if !found {
+			continue
+		}
+		uid, found := strings.CutPrefix(name, prefix)
+		if !found || uid == "" {
+			continue
+		}
+		uids = append(uids, uid)
+	}
+	return uids, nil
+}
+
+// GarbageCollectClaimSpecFiles() reconciles the claim spec files in the CDI
+// root directory with the set of checkpointed claims: spec files of claims not
+// in `claims` are deleted, and `regenerate` is called for claims in
+// PrepareCompleted state whose spec file is missing. Spec files of claims in
+// PrepareStarted state are left alone: a concurrent Prepare() may be about to
+// complete, and Unprepare() deletes the file anyway. Errors for individual
+// claims are logged, not returned.
+//
+// The caller must make sure that `claims` is not modified by a concurrent
+// Prepare() or Unprepare() while this runs; otherwise a spec file written
+// right after taking the checkpoint snapshot may be considered an orphan.
+func (cdi *CDIHandler) GarbageCollectClaimSpecFiles(ctx context.Context, claims PreparedClaimsByUID, regenerate func(claimUID string, claim PreparedClaim) error) error {
+	logger := klog.FromContext(ctx)
+	uids, err := cdi.ListClaimSpecFileUIDs()
+	if err != nil {
+		return err
+	}
+
+	onDisk := make(map[string]bool)
+	for _, uid := range uids {
+		onDisk[uid] = true
+		if _, exists := claims[uid]; exists {
+			continue
+		}
+		logger.Info("CDI spec GC: delete orphaned spec file", logKeyClaimUID, uid)
+		if err := cdi.DeleteClaimSpecFile(uid); err != nil {
+			logger.Error(err, "CDI spec GC: unable to delete spec file", logKeyClaimUID, uid)
+		}
+	}
+
+	for uid, claim := range claims {
+		if claim.CheckpointState != ClaimCheckpointStatePrepareCompleted || onDisk[uid] {
+			continue
+		}
+		claimLogger := logger.WithValues(logKeyClaim, klog.KRef(claim.Namespace, claim.Name), logKeyClaimUID, uid)
+		claimLogger.Info("CDI spec GC: regenerate missing spec file")
+		if err := regenerate(uid, claim); err != nil {
+			claimLogger.Error(err, "CDI spec GC: unable to regenerate spec file")
+		}
+	}
+
+	logger.V(4).Info("CDI spec GC: done", "specFiles", len(uids), "checkpointedClaims", len(claims))
+	return nil
+}
+
 // Philosophy: all devices to be injected into a container are defined in a
 // single, transient CDI spec. This function returns the fully qualified
 // identifier for a device defined in that spec. Example:
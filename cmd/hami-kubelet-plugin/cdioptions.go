@@ -53,6 +53,13 @@ func WithCDIRoot(cdiRoot string) cdiOption {
 	}
 }
 
+// WithDryRun provides an cdiOption to log the CDI specs written in dry-run mode.
+func WithDryRun(dryRun bool) cdiOption {
+	return func(c *CDIHandler) {
+		c.dryRun = dryRun
+	}
+}
+
 // WithNVIDIACDIHookPath provides an cdiOption to set the nvidia-cdi-hook path used by the 'cdi' interface.
 func WithNVIDIACDIHookPath(path string) cdiOption {
 	return func(c *CDIHandler) {
@@ -0,0 +1,263 @@
+/*
+Copyright 2025 The HAMi Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	resourceapi "k8s.io/api/resource/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/ptr"
+)
+
+const (
+	// UnhealthyClaimActionNone leaves claims on unhealthy devices alone.
+	UnhealthyClaimActionNone = "None"
+	// UnhealthyClaimActionClaimCondition sets the ClaimConditionDeviceUnhealthy
+	// condition in the status of the affected devices of the ResourceClaim.
+	UnhealthyClaimActionClaimCondition = "ClaimCondition"
+	// UnhealthyClaimActionAnnotatePods sets the PodAnnotationUnhealthyDevices
+	// annotation on the pods consuming the ResourceClaim.
+	UnhealthyClaimActionAnnotatePods = "AnnotatePods"
+
+	// ClaimConditionDeviceUnhealthy is True for an allocated device that was
+	// marked unhealthy after the claim was prepared.
+	ClaimConditionDeviceUnhealthy = "DeviceUnhealthy"
+
+	claimHealthUpdateTimeout = 30 * time.Second
+)
+
+// PodAnnotationUnhealthyDevices lists the devices of a consumed claim that
+// were marked unhealthy after the claim was prepared, as
+// `<claim namespace>/<claim name>/<device>` (comma-separated).
+const PodAnnotationUnhealthyDevices = DriverName + "/unhealthy-devices"
+
+func validateUnhealthyClaimAction(action string) error {
+	switch action {
+	case UnhealthyClaimActionNone, UnhealthyClaimActionClaimCondition, UnhealthyClaimActionAnnotatePods:
+		return nil
+	}
+	return fmt.Errorf("unknown unhealthy claim action: %q", action)
+}
+
+// affectedBy returns true if a health event for the allocatable device
+// affects the prepared device: it is the same device, or a MIG device of the
+// same (unhealthy) GPU.
+func (d *PreparedDevice) affectedBy(device *AllocatableDevice) bool {
+	uuid := device.UUID()
+	switch d.Type() {
+	case HAMiGpuDeviceType:
+		return d.HAMiGpu.Info.UUID == uuid
+	case GpuDeviceType:
+		return d.Gpu.Info.UUID == uuid
+	case PreparedMigDeviceType:
+		return d.Mig.Concrete.MigUUID == uuid || d.Mig.Concrete.ParentUUID == uuid
+	}
+	return false
+}
+
+// affectedDeviceNames returns the names of the prepared devices of the claim
+// affected by a health event for the allocatable device.
+func (c *PreparedClaim) affectedDeviceNames(device *AllocatableDevice) []string {
+	var names []string
+	for _, group := range c.PreparedDevices {
+		for _, d := range group.Devices {
+			if d.affectedBy(device) {
+				names = append(names, d.CanonicalName())
+			}
+		}
+	}
+	return names
+}
+
+// failClaimsOnDevice flags the completely prepared claims using the (now
+// unhealthy) device, as configured by the unhealthy claim action, so that
+// controllers can evict and reschedule their consumers. Errors are logged
+// only. Called in a goroutine of its own per health event, so it may run
+// concurrently for several devices.
+func (d *driver) failClaimsOnDevice(ctx context.Context, event *deviceHealthEvent) {
+	action := d.state.config.flags.unhealthyClaimAction
+	if action == UnhealthyClaimActionNone || !event.rule.FailClaims {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, claimHealthUpdateTimeout)
+	defer cancel()
+
+	logger := klog.FromContext(ctx).WithValues(deviceLogValues(event.device)...)
+	cp, err := d.state.getCheckpoint(ctx)
+	if err != nil {
+		logger.Error(err, "Unable to look up claims on unhealthy device")
+		return
+	}
+
+	for uid, claim := range cp.V2.PreparedClaims {
+		if claim.CheckpointState != ClaimCheckpointStatePrepareCompleted {
+			continue
+		}
+		devices := claim.affectedDeviceNames(event.device)
+		if len(devices) == 0 {
+			continue
+		}
+
+		claimLogger := logger.WithValues(logKeyClaim, klog.KRef(claim.Namespace, claim.Name), logKeyClaimUID, uid)
+		claimLogger.Info("Claim uses unhealthy device(s)", "devices", devices, "action", action)
+		if d.state.config.flags.dryRun {
+			claimLogger.Info("Dry-run: not flagging claim")
+			continue
+		}
+		switch action {
+		case UnhealthyClaimActionClaimCondition:
+			err = d.setDeviceUnhealthyCondition(ctx, types.UID(uid), claim, devices, event)
+		case UnhealthyClaimActionAnnotatePods:
+			err = d.annotateConsumerPods(ctx, claim, devices)
+		}
+		if err != nil {
+			claimLogger.Error(err, "Unable to flag claim using unhealthy device(s)")
+		}
+	}
+}
+
+// setDeviceUnhealthyCondition sets ClaimConditionDeviceUnhealthy in the
+// status of the unhealthy devices of the claim. The status is also written by
+// the scheduler and other drivers, so conflicting updates are retried.
+func (d *driver) setDeviceUnhealthyCondition(ctx context.Context, uid types.UID, prepared PreparedClaim, devices []string, event *deviceHealthEvent) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		return d.updateDeviceUnhealthyCondition(ctx, uid, prepared, devices, event)
+	})
+}
+
+func (d *driver) updateDeviceUnhealthyCondition(ctx context.Context, uid types.UID, prepared PreparedClaim, devices []string, event *deviceHealthEvent) error {
+	client := d.state.config.clientsets.Resource.ResourceClaims(prepared.Namespace)
+	claim, err := client.Get(ctx, prepared.Name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("error getting ResourceClaim: %w", err)
+	}
+	if claim.UID != uid || claim.Status.Allocation == nil {
+		// Gone (and re-created); the cleanup takes care of it.
+		return nil
+	}
+
+	condition := metav1.Condition{
+		Type:               ClaimConditionDeviceUnhealthy,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: claim.Generation,
+		Reason:             string(event.rule.Action),
+		Message:            fmt.Sprintf("Device marked unhealthy: %s", event.reason),
+	}
+
+	for _, result := range claim.Status.Allocation.Devices.Results {
+		if result.Driver != DriverName || !slices.Contains(devices, result.Device) {
+			continue
+		}
+		var shareID *string
+		if result.ShareID != nil {
+			shareID = ptr.To(string(*result.ShareID))
+		}
+		i := slices.IndexFunc(claim.Status.Devices, func(s resourceapi.AllocatedDeviceStatus) bool {
+			return s.Driver == result.Driver && s.Pool == result.Pool && s.Device == result.Device && ptr.Equal(s.ShareID, shareID)
+		})
+		if i < 0 {
+			claim.Status.Devices = append(claim.Status.Devices, resourceapi.AllocatedDeviceStatus{
+				Driver:  result.Driver,
+				Pool:    result.Pool,
+				Device:  result.Device,
+				ShareID: shareID,
+			})
+			i = len(claim.Status.Devices) - 1
+		}
+		apimeta.SetStatusCondition(&claim.Status.Devices[i].Conditions, condition)
+	}
+
+	if _, err := client.UpdateStatus(ctx, claim, metav1.UpdateOptions{}); err != nil {
+		// Wrapped with %w, so that RetryOnConflict still sees the conflict.
+		return fmt.Errorf("error updating ResourceClaim status: %w", err)
+	}
+	return nil
+}
+
+// annotateConsumerPods adds the unhealthy devices of the claim to the
+// PodAnnotationUnhealthyDevices annotation of the pods the claim is reserved
+// for.
+func (d *driver) annotateConsumerPods(ctx context.Context, claim PreparedClaim, devices []string) error {
+	for _, consumer := range claim.Status.ReservedFor {
+		if consumer.APIGroup != "" || consumer.Resource != "pods" {
+			continue
+		}
+		// The annotation is read, extended and written back: the patch
+		// carries the resourceVersion, and concurrent updates (e.g. for
+		// another device of the pod) are retried.
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			return d.annotateConsumerPod(ctx, claim, consumer.Name, consumer.UID, devices)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *driver) annotateConsumerPod(ctx context.Context, claim PreparedClaim, name string, uid types.UID, devices []string) error {
+	pods := d.state.config.clientsets.Core.CoreV1().Pods(claim.Namespace)
+	pod, err := pods.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("error getting pod %s: %w", name, err)
+	}
+	if pod.UID != uid {
+		return nil
+	}
+
+	var entries []string
+	if existing := pod.Annotations[PodAnnotationUnhealthyDevices]; existing != "" {
+		entries = strings.Split(existing, ",")
+	}
+	n := len(entries)
+	for _, device := range devices {
+		entry := claim.Namespace + "/" + claim.Name + "/" + device
+		if !slices.Contains(entries, entry) {
+			entries = append(entries, entry)
+		}
+	}
+	if len(entries) == n {
+		return nil
+	}
+
+	patch, err := json.Marshal(map[string]any{
+		"metadata": map[string]any{
+			"resourceVersion": pod.ResourceVersion,
+			"annotations": map[string]string{
+				PodAnnotationUnhealthyDevices: strings.Join(entries, ","),
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("error building pod patch: %w", err)
+	}
+	if _, err := pods.Patch(ctx, pod.Name, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		return fmt.Errorf("error patching pod %s: %w", pod.Name, err)
+	}
+	return nil
+}
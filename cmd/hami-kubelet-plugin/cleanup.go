@@ -20,13 +20,18 @@ package main
 import (
 	"context"
 	"fmt"
+	"math/rand/v2"
 	"sync"
 	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	resourcev1 "k8s.io/api/resource/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
 	draclient "k8s.io/dynamic-resource-allocation/client"
 	"k8s.io/dynamic-resource-allocation/kubeletplugin"
 	"k8s.io/klog/v2"
@@ -34,28 +39,83 @@ import (
 
 const (
 	ResourceClaimCleanupInterval = 10 * time.Minute
+
+	// Upper bound for the delay between two cleanup runs when backing off
+	// after API server errors.
+	ResourceClaimCleanupMaxBackoff = time.Hour
+
+	// ClaimCleanupModePoll periodically looks up each partially prepared
+	// claim in the API server (one Get() per claim).
+	ClaimCleanupModePoll = "poll"
+	// ClaimCleanupModeInformer watches ResourceClaim objects and reacts to
+	// claims allocated to this node going away. Covers both, partially and
+	// completely prepared claims.
+	ClaimCleanupModeInformer = "informer"
 )
 
 type TypeUnprepCallable = func(ctx context.Context, claimRef kubeletplugin.NamespacedObject) error
 
+type CheckpointCleanupConfig struct {
+	// NodeName is the name of the node (and hence of the ResourceSlice pool)
+	// this plugin is responsible for.
+	NodeName string
+	// Mode is one of ClaimCleanupModePoll, ClaimCleanupModeInformer.
+	Mode string
+	// DryRun makes the cleanup manager log which claims it would unprepare
+	// instead of unpreparing them.
+	DryRun bool
+	// Interval between two cleanup runs (in the absence of errors).
+	Interval time.Duration
+	// StartJitter is the upper bound for the random delay of the first
+	// cleanup run after startup.
+	StartJitter time.Duration
+	// MaxUnprepareAttempts is the number of failed unprepare attempts for a
+	// stale claim after which the cleanup manager gives up on that claim
+	// (until restart). Zero means: never give up.
+	MaxUnprepareAttempts int
+}
+
 type CheckpointCleanupManager struct {
 	waitGroup     sync.WaitGroup
 	cancelContext context.CancelFunc
-	queue         chan struct{}
 	devicestate   *DeviceState
 	draclient     *draclient.Client
+	config        CheckpointCleanupConfig
+
+	// Number of consecutive cleanup runs that failed because of API server errors.
+	// Only accessed by worker().
+	apiErrorStreak int
+
+	// Failed unprepare attempts per claim UID.
+	unprepareFailuresMutex sync.Mutex
+	unprepareFailures      map[string]int
+
+	// State of cleanupNamelessClaims().
+	legacyMutex       sync.Mutex
+	uidIndex          *claimUIDIndex
+	namelessFirstSeen map[string]time.Time
+
+	// Only set in ClaimCleanupModeInformer.
+	informer   cache.SharedIndexInformer
+	claimQueue workqueue.TypedRateLimitingInterface[string]
+	// UIDs of claims seen allocated to this node, so that the deletion of a
+	// claim can be detected after it has been deallocated.
+	allocatedUIDs sync.Map
 
 	unprepfunc TypeUnprepCallable
 }
 
-func NewCheckpointCleanupManager(s *DeviceState, client *draclient.Client) *CheckpointCleanupManager {
-	// `queue`: buffered channel to implement a pragmatic fixed-size queue; to
-	// ensure at most one cleanup operation gets enqueued. TODO: review
-	// replacing this with a condition variable.
+func NewCheckpointCleanupManager(s *DeviceState, client *draclient.Client, config CheckpointCleanupConfig) *CheckpointCleanupManager {
+	if config.Interval <= 0 {
+		config.Interval = ResourceClaimCleanupInterval
+	}
 	return &CheckpointCleanupManager{
 		devicestate: s,
 		draclient:   client,
-		queue:       make(chan struct{}, 1),
+		config:      config,
+
+		unprepareFailures: make(map[string]int),
+		namelessFirstSeen: make(map[string]time.Time),
 	}
 }
 
@@ -64,21 +124,34 @@ func (m *CheckpointCleanupManager) Start(ctx context.Context, unprepfunc TypeUnp
 	m.cancelContext = cancel
 	m.unprepfunc = unprepfunc
 
-	m.waitGroup.Add(1)
-	go func() {
-		defer m.waitGroup.Done()
-		// Start producer: periodically submit cleanup task.
-		m.triggerPeriodically(ctx)
-	}()
+	switch m.config.Mode {
+	case ClaimCleanupModePoll:
+	case ClaimCleanupModeInformer:
+		if err := m.startInformer(ctx); err != nil {
+			cancel()
+			return fmt.Errorf("error starting ResourceClaim informer: %w", err)
+		}
+	default:
+		cancel()
+		return fmt.Errorf("unknown claim cleanup mode: %q", m.config.Mode)
+	}
+
+	if m.config.DryRun {
+		klog.Infof("Checkpointed RC cleanup: dry-run mode, stale claims will not be unprepared")
+	}
 
 	m.waitGroup.Add(1)
 	go func() {
 		defer m.waitGroup.Done()
-		// Start consumer
+		// The periodic sweep consults the informer cache: do not start it
+		// before the cache reflects the API server state.
+		if m.informer != nil && !cache.WaitForCacheSync(ctx.Done(), m.informer.HasSynced) {
+			return
+		}
 		m.worker(ctx)
 	}()
 
-	klog.V(6).Infof("CheckpointCleanupManager started")
+	klog.V(6).Infof("CheckpointCleanupManager started (mode: %s, interval: %s)", m.config.Mode, m.config.Interval)
 	return nil
 }
 
@@ -90,12 +163,14 @@ func (m *CheckpointCleanupManager) Stop() error {
 	return nil
 }
 
-// cleanup() is the high-level cleanup routine run once upon plugin startup and
-// then periodically. It gets all claims in PrepareStarted state from the
-// current checkpoint, and runs `unprepareIfStale()` for each of them. Each
+// cleanup() is the high-level cleanup routine run once shortly after plugin
+// startup and then periodically. It gets all claims in PrepareStarted state
+// from the current checkpoint, and runs `unprepareIfStale()` for each of them. In
+// informer mode, it instead hands all checkpointed claims that the informer
+// cache does not confirm to the rate-limited claim queue. Each
 // invocation of `cleanup()` and each invocation of `unprepareIfStale()` is
-// best-effort: errors do not need to be propagated (but are expected to be
-// properly logged).
+// best-effort: errors are logged. The returned error only signals that the API
+// server could not be talked to, so that the next run can be delayed.
 //
 // Note: This function does not acquire DeviceState lock when reading the checkpoint.
 // The lock is not needed because:
@@ -108,11 +183,22 @@ func (m *CheckpointCleanupManager) Stop() error {
 //     acquires the pulock (process-level file lock) for atomic read-modify-write.
 //  4. Holding DeviceState lock during the entire cleanup (which could take seconds with
 //     multiple API calls) would unnecessarily block normal Prepare/Unprepare operations.
-func (m *CheckpointCleanupManager) cleanup(ctx context.Context) {
+func (m *CheckpointCleanupManager) cleanup(ctx context.Context) error {
 	cp, err := m.devicestate.getCheckpoint(ctx)
 	if err != nil {
 		klog.Errorf("Checkpointed RC cleanup: unable to get checkpoint: %s", err)
-		return
+		return nil
+	}
+
+	m.pruneUnprepareFailures(cp)
+
+	if err := m.cleanupNamelessClaims(ctx, cp); err != nil {
+		return err
+	}
+
+	if m.informer != nil {
+		m.enqueueUnconfirmedClaims(cp)
+		return nil
 	}
 
 	// Get checkpointed claims in PrepareStarted state.
@@ -126,8 +212,13 @@ func (m *CheckpointCleanupManager) cleanup(ctx context.Context) {
 	klog.V(4).Infof("Checkpointed RC cleanup: claims in PrepareStarted state: %d (of %d)", len(filtered), len(cp.V2.PreparedClaims))
 
 	for cpuid, cpclaim := range filtered {
-		m.unprepareIfStale(ctx, cpuid, cpclaim)
+		if err := m.unprepareIfStale(ctx, cpuid, cpclaim); err != nil {
+			// Do not add to the load of a struggling API server by looking
+			// up the remaining claims now.
+			return err
+		}
 	}
+	return nil
 }
 
 // Detect if claim is stale (not known to the API server). Call unprepare() if
@@ -146,30 +237,30 @@ func (m *CheckpointCleanupManager) cleanup(ctx context.Context) {
 //
 // For (2), name and namespace must be stored in the checkpoint. That is not
 // true for legacy deployments with checkpoint data created by version 25.3.x of
-// this driver. Detect that situation by looking for an empty `Name`.
-func (m *CheckpointCleanupManager) unprepareIfStale(ctx context.Context, cpuid string, cpclaim PreparedClaim) {
+// this driver. Detect that situation by looking for an empty `Name`; those
+// claims are taken care of by cleanupNamelessClaims().
+//
+// Return an error only if the API server lookup failed.
+func (m *CheckpointCleanupManager) unprepareIfStale(ctx context.Context, cpuid string, cpclaim PreparedClaim) error {
+	ctx = withClaimLogger(ctx, cpclaim.Namespace, cpclaim.Name, types.UID(cpuid))
+	logger := klog.FromContext(ctx)
 	if cpclaim.Name == "" {
-		klog.V(4).Infof("Checkpointed RC cleanup: skip checkpointed claim '%s': RC name not in checkpoint", cpuid)
-		// Consider fallback: expensive lookup by UID across all namespaces.
-		return
+		logger.V(6).Info("Checkpointed RC cleanup: skip checkpointed claim: RC name not in checkpoint")
+		return nil
 	}
 
 	claim, err := m.getClaimByName(ctx, cpclaim.Name, cpclaim.Namespace)
 	if err != nil && errors.IsNotFound(err) {
-		klog.V(4).Infof(
-			"Checkpointed RC cleanup: partially prepared claim '%s/%s:%s' is stale: not found in API server",
-			cpclaim.Namespace,
-			cpclaim.Name,
-			cpuid)
-		m.unprepare(ctx, cpuid, cpclaim)
-		return
+		logger.V(4).Info("Checkpointed RC cleanup: partially prepared claim is stale: not found in API server")
+		_ = m.unprepare(ctx, cpuid, cpclaim)
+		return nil
 	}
 
 	// A transient error during API server lookup. No explicit retry required.
 	// The next periodic cleanup invocation will implicitly retry.
 	if err != nil {
-		klog.Infof("Checkpointed RC cleanup: skip for checkpointed claim %s: getClaimByName failed (retry later): %s", cpuid, err)
-		return
+		logger.Info("Checkpointed RC cleanup: skip for checkpointed claim: getClaimByName failed (retry later)", "err", err)
+		return err
 	}
 
 	if string(claim.UID) != cpuid {
@@ -178,18 +269,19 @@ func (m *CheckpointCleanupManager) unprepareIfStale(ctx context.Context, cpuid s
 		// ResourceClaim with the same name to have a different UID if the
 		// original object was deleted and a new one with the same name was
 		// created. Hence, this checkpointed claim is stale.
-		klog.V(4).Infof("Checkpointed RC cleanup: partially prepared claim '%s/%s' is stale: UID changed (checkpoint: %s, API server: %s)", cpclaim.Namespace, cpclaim.Name, cpuid, claim.UID)
-		m.unprepare(ctx, cpuid, cpclaim)
-		return
+		logger.V(4).Info("Checkpointed RC cleanup: partially prepared claim is stale: UID changed", "apiServerClaimUID", claim.UID)
+		_ = m.unprepare(ctx, cpuid, cpclaim)
+		return nil
 	}
 
-	klog.V(4).Infof("Checkpointed RC cleanup: partially prepared claim not stale: %s", ResourceClaimToString(claim))
+	logger.V(4).Info("Checkpointed RC cleanup: partially prepared claim not stale")
+	return nil
 }
 
 // unprepare() attempts to unprepare devices for the provided claim
 // ('self-initiated unprepare'). Expected side effect: removal of the
-// corresponding claim from the checkpoint.
-func (m *CheckpointCleanupManager) unprepare(ctx context.Context, uid string, claim PreparedClaim) {
+// corresponding claim from the checkpoint. In dry-run mode, only log.
+func (m *CheckpointCleanupManager) unprepare(ctx context.Context, uid string, claim PreparedClaim) error {
 	claimRef := kubeletplugin.NamespacedObject{
 		UID: types.UID(uid),
 		NamespacedName: types.NamespacedName{
@@ -197,19 +289,105 @@ func (m *CheckpointCleanupManager) unprepare(ctx context.Context, uid string, cl
 			Namespace: claim.Namespace,
 		},
 	}
+	ctx = withClaimLogger(ctx, claim.Namespace, claim.Name, claimRef.UID)
+	logger := klog.FromContext(ctx)
+
+	if m.config.DryRun {
+		logger.Info("Checkpointed RC cleanup: dry-run: would unprepare stale claim", "checkpointState", claim.CheckpointState)
+		return nil
+	}
+
+	if m.unprepareBudgetExhausted(uid) {
+		logger.V(6).Info("Checkpointed RC cleanup: skip claim: gave up on unpreparing it")
+		return nil
+	}
 
 	// Perform one Unprepare attempt. Implicit retrying across periodic cleanup
 	// invocations is sufficient. Rely on Unprepare() to delete claim from
-	// checkpoint (upon success). TODO: review `Unprepare()` for code paths that
-	// allow for this claim never to be dropped from the checkpoint (resulting
-	// in infinite periodic cleanup attempts for this claim).
+	// checkpoint (upon success). Code paths in `Unprepare()` that never allow
+	// for this claim to be dropped from the checkpoint are cut short by the
+	// retry budget.
 	err := m.unprepfunc(ctx, claimRef)
 	if err != nil {
-		klog.Warningf("Checkpointed RC cleanup: error during unprepare for %s (retried later): %s", claimRef.String(), err)
-		return
+		claimCleanupUnprepareFailures.Inc()
+		if m.recordUnprepareFailure(ctx, claimRef, err) {
+			return nil
+		}
+		logger.Info("Checkpointed RC cleanup: error during unprepare (retried later)", "err", err)
+		return err
+	}
+
+	m.unprepareFailuresMutex.Lock()
+	delete(m.unprepareFailures, uid)
+	m.unprepareFailuresMutex.Unlock()
+
+	claimCleanupUnpreparedClaims.Inc()
+	logger.Info("Checkpointed RC cleanup: unprepared stale claim")
+	config := m.devicestate.config
+	for _, ref := range claimEventRefs(claim.Namespace, claim.Name, claimRef.UID, claim.Status.ReservedFor) {
+		config.eventRecorder.Event(ref, corev1.EventTypeNormal, "StaleClaimUnprepared", "Unprepared devices of stale claim (no longer allocated to this node, or deleted)")
+	}
+	return nil
+}
+
+func (m *CheckpointCleanupManager) unprepareBudgetExhausted(uid string) bool {
+	if m.config.MaxUnprepareAttempts <= 0 {
+		return false
+	}
+	m.unprepareFailuresMutex.Lock()
+	defer m.unprepareFailuresMutex.Unlock()
+	return m.unprepareFailures[uid] >= m.config.MaxUnprepareAttempts
+}
+
+// recordUnprepareFailure() counts a failed unprepare attempt. When this
+// exhausts the retry budget for the claim, escalate (Event, metric) and return
+// true: the claim will not be retried anymore by this process, and requires
+// human attention.
+func (m *CheckpointCleanupManager) recordUnprepareFailure(ctx context.Context, claimRef kubeletplugin.NamespacedObject, err error) bool {
+	m.unprepareFailuresMutex.Lock()
+	uid := string(claimRef.UID)
+	m.unprepareFailures[uid]++
+	attempts := m.unprepareFailures[uid]
+	m.setAbandonedClaimsLocked()
+	m.unprepareFailuresMutex.Unlock()
+
+	if m.config.MaxUnprepareAttempts <= 0 || attempts < m.config.MaxUnprepareAttempts {
+		return false
 	}
 
-	klog.Infof("Checkpointed RC cleanup: unprepared stale claim: %s", claimRef.String())
+	msg := fmt.Sprintf("Giving up on unpreparing stale claim %s after %d failed attempts: %s", claimRef.String(), attempts, err)
+	klog.FromContext(ctx).Error(err, "Checkpointed RC cleanup: giving up on unpreparing stale claim", "attempts", attempts)
+	config := m.devicestate.config
+	config.eventRecorder.Event(config.NodeRef(), corev1.EventTypeWarning, "StaleClaimUnprepareFailed", msg)
+	return true
+}
+
+// pruneUnprepareFailures() forgets the failed unprepare attempts of claims
+// that are not in the checkpoint anymore (e.g. unprepared by the kubelet).
+func (m *CheckpointCleanupManager) pruneUnprepareFailures(cp *Checkpoint) {
+	m.unprepareFailuresMutex.Lock()
+	defer m.unprepareFailuresMutex.Unlock()
+	for uid := range m.unprepareFailures {
+		if _, exists := cp.V2.PreparedClaims[uid]; !exists {
+			delete(m.unprepareFailures, uid)
+		}
+	}
+	m.setAbandonedClaimsLocked()
+}
+
+// setAbandonedClaimsLocked() sets the abandoned claims metric to the number of
+// checkpointed claims with an exhausted retry budget. Call with
+// unprepareFailuresMutex held.
+func (m *CheckpointCleanupManager) setAbandonedClaimsLocked() {
+	var n int
+	if m.config.MaxUnprepareAttempts > 0 {
+		for _, attempts := range m.unprepareFailures {
+			if attempts >= m.config.MaxUnprepareAttempts {
+				n++
+			}
+		}
+	}
+	claimCleanupAbandonedClaims.Set(float64(n))
 }
 
 // getClaimByName() attempts to fetch a ResourceClaim object directly from the
@@ -221,7 +399,9 @@ func (m *CheckpointCleanupManager) getClaimByName(ctx context.Context, name stri
 	childctx, cancel := context.WithTimeout(ctx, 20*time.Second)
 	defer cancel()
 
+	childctx, span := startSpan(childctx, "GetResourceClaim", attrClaimNamespace.String(ns), attrClaimName.String(name))
 	claim, err := m.draclient.ResourceClaims(ns).Get(childctx, name, metav1.GetOptions{})
+	endSpan(span, err)
 	if err != nil {
 		return nil, fmt.Errorf("error getting resource claim %s/%s: %w", name, ns, err)
 	}
@@ -229,55 +409,60 @@ func (m *CheckpointCleanupManager) getClaimByName(ctx context.Context, name stri
 	return claim, nil
 }
 
-// enqueueCleanup() submits a cleanup task if the queue is currently empty.
-// Return a Boolean indicating whether the task was submitted or not.
-func (m *CheckpointCleanupManager) enqueueCleanup() bool {
-	select {
-	case m.queue <- struct{}{}:
-		return true
-	default:
-		// Channel full: one task already lined up, did not submit more.
-		return false
+// Run cleanup() forever until context is canceled. The first run is delayed by
+// a random amount of time (up to StartJitter): after a driver upgrade the
+// plugins on all nodes restart at roughly the same time, and their cleanup
+// runs should not hit the API server at the same time. Subsequent runs are
+// spaced by Interval (with some jitter), or more when backing off.
+func (m *CheckpointCleanupManager) worker(ctx context.Context) {
+	var delay time.Duration
+	if m.config.StartJitter > 0 {
+		delay = rand.N(m.config.StartJitter)
 	}
-}
+	klog.V(4).Infof("Checkpointed RC cleanup: first run in %s", delay)
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	defer backgroundLoops.stop(loopClaimCleanup)
 
-// Run forever until context is canceled.
-func (m *CheckpointCleanupManager) worker(ctx context.Context) {
 	for {
+		// Allow a cleanup run to take as long as the delay before it.
+		backgroundLoops.beat(loopClaimCleanup, 2*delay+time.Minute)
 		select {
 		case <-ctx.Done():
 			return
-		case <-m.queue:
-			// Do we want to timeout-control this cleanup run? What may take
-			// unexpectedly long: lock acquisition (if we do any, e.g. around
-			// checkpoint file mutation), API server interaction.
-			m.cleanup(ctx)
+		case <-timer.C:
 		}
+
+		// Do we want to timeout-control this cleanup run? What may take
+		// unexpectedly long: lock acquisition (if we do any, e.g. around
+		// checkpoint file mutation), API server interaction.
+		t0 := time.Now()
+		err := m.cleanup(ctx)
+		claimCleanupRuns.Inc()
+		claimCleanupRunDuration.Observe(time.Since(t0).Seconds())
+		klog.V(6).Infof("t_cleanup %.3f s", time.Since(t0).Seconds())
+
+		delay = m.nextDelay(err)
+		timer.Reset(delay)
 	}
 }
 
-// Immediately submit a cleanup task; then periodically submit cleanup tasks
-// forever.
-func (m *CheckpointCleanupManager) triggerPeriodically(ctx context.Context) {
-	// Maybe add jitter. Or delay first cleanup by a somewhat random amount.
-	// After all, this periodic cleanup runs in N kubelet plugins and upon
-	// driver upgrade they might restart at roughly the same time -- it makes
-	// sense to smear the API server load out over time.
-	ticker := time.NewTicker(ResourceClaimCleanupInterval)
-	defer ticker.Stop()
-
-	m.cleanup(ctx)
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-ticker.C:
-			if m.enqueueCleanup() {
-				klog.V(6).Infof("Checkpointed RC cleanup: task submitted")
-			} else {
-				// A previous cleanup is taking long; that may not be normal.
-				klog.Warningf("Checkpointed RC cleanup: ongoing, skipped")
-			}
-		}
+// nextDelay() returns the time to wait until the next cleanup run: Interval
+// when the last run succeeded, exponentially more (capped) for every
+// consecutive run that failed because of API server errors.
+func (m *CheckpointCleanupManager) nextDelay(lastErr error) time.Duration {
+	if lastErr == nil {
+		m.apiErrorStreak = 0
+		return wait.Jitter(m.config.Interval, 0.1)
+	}
+
+	m.apiErrorStreak++
+	delay := m.config.Interval
+	for i := 0; i < m.apiErrorStreak && delay < ResourceClaimCleanupMaxBackoff; i++ {
+		delay *= 2
 	}
+	delay = min(delay, ResourceClaimCleanupMaxBackoff)
+	klog.Warningf("Checkpointed RC cleanup: API server errors in %d consecutive run(s), next run in %s", m.apiErrorStreak, delay)
+	return wait.Jitter(delay, 0.1)
 }
@@ -0,0 +1,282 @@
+/*
+Copyright 2025 The HAMi Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"golang.org/x/time/rate"
+	resourcev1 "k8s.io/api/resource/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// Unprepare may tear down MIG devices or stop MPS control daemons: do not
+	// let a burst of deletions (e.g. a namespace being removed) turn into a
+	// burst of such operations.
+	claimCleanupQPS   = 1
+	claimCleanupBurst = 5
+
+	claimCleanupRetryBaseDelay = 5 * time.Second
+	claimCleanupRetryMaxDelay  = 5 * time.Minute
+)
+
+// startInformer sets up a ResourceClaim informer and the rate-limited queue of
+// claim UIDs consumed by claimWorker().
+//
+// ResourceClaims only support field selectors on metadata fields, i.e. the API
+// server cannot be asked for "claims allocated to pool X". The informer hence
+// watches all claims and the event handlers select those allocated to this
+// node (see allocatedToNode()). Checkpointed claims are by definition
+// allocated to this node, so nothing relevant is filtered out.
+func (m *CheckpointCleanupManager) startInformer(ctx context.Context) error {
+	lw := &cache.ListWatch{
+		ListWithContextFunc: func(ctx context.Context, options metav1.ListOptions) (runtime.Object, error) {
+			return m.draclient.ResourceClaims(metav1.NamespaceAll).List(ctx, options)
+		},
+		WatchFuncWithContext: func(ctx context.Context, options metav1.ListOptions) (watch.Interface, error) {
+			return m.draclient.ResourceClaims(metav1.NamespaceAll).Watch(ctx, options)
+		},
+	}
+
+	m.informer = cache.NewSharedIndexInformer(lw, &resourcev1.ResourceClaim{}, 0, cache.Indexers{})
+
+	// The cache holds claims of the entire cluster: only keep what is looked
+	// at here.
+	if err := m.informer.SetTransform(m.slimClaim); err != nil {
+		return fmt.Errorf("error setting informer transform: %w", err)
+	}
+
+	_, err := m.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    m.onClaimAddOrUpdate,
+		UpdateFunc: func(_, newObj any) { m.onClaimAddOrUpdate(newObj) },
+		DeleteFunc: m.onClaimDelete,
+	})
+	if err != nil {
+		return fmt.Errorf("error adding event handler: %w", err)
+	}
+
+	m.claimQueue = workqueue.NewTypedRateLimitingQueueWithConfig(
+		workqueue.NewTypedMaxOfRateLimiter(
+			workqueue.NewTypedItemExponentialFailureRateLimiter[string](claimCleanupRetryBaseDelay, claimCleanupRetryMaxDelay),
+			&workqueue.TypedBucketRateLimiter[string]{Limiter: rate.NewLimiter(rate.Limit(claimCleanupQPS), claimCleanupBurst)},
+		),
+		workqueue.TypedRateLimitingQueueConfig[string]{Name: "checkpointed-claim-cleanup"},
+	)
+
+	m.waitGroup.Add(1)
+	go func() {
+		defer m.waitGroup.Done()
+		m.informer.RunWithContext(ctx)
+	}()
+
+	m.waitGroup.Add(1)
+	go func() {
+		defer m.waitGroup.Done()
+		<-ctx.Done()
+		m.claimQueue.ShutDown()
+	}()
+
+	m.waitGroup.Add(1)
+	go func() {
+		defer m.waitGroup.Done()
+		if !cache.WaitForCacheSync(ctx.Done(), m.informer.HasSynced) {
+			return
+		}
+		m.claimWorker(ctx)
+	}()
+
+	return nil
+}
+
+// slimClaim is the informer transform. It reduces a claim to its identity
+// and, if allocated to this node, the allocation results of this node.
+func (m *CheckpointCleanupManager) slimClaim(obj any) (any, error) {
+	claim, ok := obj.(*resourcev1.ResourceClaim)
+	if !ok {
+		// E.g. a tombstone.
+		return obj, nil
+	}
+
+	slim := &resourcev1.ResourceClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            claim.Name,
+			Namespace:       claim.Namespace,
+			UID:             claim.UID,
+			ResourceVersion: claim.ResourceVersion,
+		},
+	}
+	if claim.Status.Allocation == nil {
+		return slim, nil
+	}
+	var results []resourcev1.DeviceRequestAllocationResult
+	for _, r := range claim.Status.Allocation.Devices.Results {
+		if r.Driver == DriverName && r.Pool == m.config.NodeName {
+			results = append(results, resourcev1.DeviceRequestAllocationResult{Driver: r.Driver, Pool: r.Pool, Device: r.Device})
+		}
+	}
+	if len(results) > 0 {
+		slim.Status.Allocation = &resourcev1.AllocationResult{
+			Devices: resourcev1.DeviceAllocationResult{Results: results},
+		}
+	}
+	return slim, nil
+}
+
+// allocatedToNode returns true if at least one device allocated to the claim
+// is served by this driver from this node's pool.
+func (m *CheckpointCleanupManager) allocatedToNode(claim *resourcev1.ResourceClaim) bool {
+	if claim.Status.Allocation == nil {
+		return false
+	}
+	for _, r := range claim.Status.Allocation.Devices.Results {
+		if r.Driver == DriverName && r.Pool == m.config.NodeName {
+			return true
+		}
+	}
+	return false
+}
+
+// onClaimAddOrUpdate remembers claims allocated to this node. A claim is
+// deallocated before it is deleted (the delete-protection finalizer ensures
+// that), so the final object seen in onClaimDelete() usually does not refer
+// to this node anymore.
+func (m *CheckpointCleanupManager) onClaimAddOrUpdate(obj any) {
+	claim, ok := obj.(*resourcev1.ResourceClaim)
+	if !ok {
+		return
+	}
+	if m.allocatedToNode(claim) {
+		m.allocatedUIDs.Store(string(claim.UID), struct{}{})
+	}
+}
+
+func (m *CheckpointCleanupManager) onClaimDelete(obj any) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	claim, ok := obj.(*resourcev1.ResourceClaim)
+	if !ok {
+		return
+	}
+
+	uid := string(claim.UID)
+	_, seen := m.allocatedUIDs.LoadAndDelete(uid)
+	if !seen && !m.allocatedToNode(claim) {
+		return
+	}
+
+	klog.V(4).InfoS("Checkpointed RC cleanup: claim deleted from API server", logKeyClaim, klog.KObj(claim), logKeyClaimUID, claim.UID)
+	m.claimQueue.AddRateLimited(uid)
+}
+
+// enqueueUnconfirmedClaims is the informer mode equivalent of the periodic
+// sweep: every checkpointed claim (independent of its checkpoint state) that
+// is not present with the same UID in the informer cache gets queued for a
+// closer look. This catches claims deleted while this plugin was not running.
+func (m *CheckpointCleanupManager) enqueueUnconfirmedClaims(cp *Checkpoint) {
+	var n int
+	for uid, cpclaim := range cp.V2.PreparedClaims {
+		if cpclaim.Name == "" {
+			// Taken care of by cleanupNamelessClaims().
+			continue
+		}
+		if claim := m.getCachedClaim(cpclaim.Name, cpclaim.Namespace); claim != nil && string(claim.UID) == uid {
+			continue
+		}
+		m.claimQueue.AddRateLimited(uid)
+		n++
+	}
+	klog.V(4).Infof("Checkpointed RC cleanup: claims not confirmed by informer cache: %d (of %d)", n, len(cp.V2.PreparedClaims))
+}
+
+func (m *CheckpointCleanupManager) getCachedClaim(name, ns string) *resourcev1.ResourceClaim {
+	obj, exists, err := m.informer.GetIndexer().GetByKey(ns + "/" + name)
+	if err != nil || !exists {
+		return nil
+	}
+	claim, ok := obj.(*resourcev1.ResourceClaim)
+	if !ok {
+		return nil
+	}
+	return claim
+}
+
+func (m *CheckpointCleanupManager) claimWorker(ctx context.Context) {
+	for {
+		uid, shutdown := m.claimQueue.Get()
+		if shutdown {
+			return
+		}
+
+		err := m.processClaim(ctx, uid)
+		if err != nil {
+			klog.V(2).InfoS("Checkpointed RC cleanup: requeue claim", logKeyClaimUID, uid, "err", err)
+			m.claimQueue.AddRateLimited(uid)
+		} else {
+			m.claimQueue.Forget(uid)
+		}
+		m.claimQueue.Done(uid)
+	}
+}
+
+// processClaim unprepares the checkpointed claim with the given UID if it is
+// stale. Return an error if the claim should be looked at again later.
+func (m *CheckpointCleanupManager) processClaim(ctx context.Context, uid string) error {
+	cp, err := m.devicestate.getCheckpoint(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to get checkpoint: %w", err)
+	}
+
+	cpclaim, exists := cp.V2.PreparedClaims[uid]
+	if !exists {
+		// Unprepared in the meantime (typically by the kubelet).
+		return nil
+	}
+	if cpclaim.Name == "" {
+		return nil
+	}
+
+	// The informer cache may lag behind (e.g. for a claim that was just
+	// created and is being prepared right now): only ever act on what the
+	// API server says.
+	ctx = withClaimLogger(ctx, cpclaim.Namespace, cpclaim.Name, types.UID(uid))
+	logger := klog.FromContext(ctx)
+	claim, err := m.getClaimByName(ctx, cpclaim.Name, cpclaim.Namespace)
+	switch {
+	case err != nil && errors.IsNotFound(err):
+		logger.V(4).Info("Checkpointed RC cleanup: claim is stale: not found in API server", "checkpointState", cpclaim.CheckpointState)
+	case err != nil:
+		return err
+	case string(claim.UID) != uid:
+		logger.V(4).Info("Checkpointed RC cleanup: claim is stale: UID changed", "checkpointState", cpclaim.CheckpointState, "apiServerClaimUID", claim.UID)
+	default:
+		logger.V(6).Info("Checkpointed RC cleanup: claim not stale")
+		return nil
+	}
+
+	return m.unprepare(ctx, uid, cpclaim)
+}
@@ -0,0 +1,168 @@
+/*
+Copyright 2025 The HAMi Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	resourcev1 "k8s.io/api/resource/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/pager"
+	"k8s.io/klog/v2"
+)
+
+const (
+	claimListPageSize = 500
+	claimListTimeout  = 2 * time.Minute
+)
+
+// claimUIDIndex is a snapshot of all ResourceClaims in the cluster, keyed by
+// UID. `listedAt` is the time the (paginated) list operation was started.
+type claimUIDIndex struct {
+	byUID    map[string]types.NamespacedName
+	listedAt time.Time
+}
+
+// cleanupNamelessClaims() takes care of checkpointed claims without name and
+// namespace (checkpoint data created by version 25.3.x of this driver, or by
+// older versions of Prepare() not carrying the name over into the
+// PrepareCompleted state). Those cannot be looked up with a cheap
+// Get(). Instead, look them up by UID in a cluster-wide list of claims. That
+// list is expensive for the API server: it is only taken when there are such
+// claims, and at most once per cleanup interval.
+//
+// A claim found in the list gets its name and namespace written to the
+// checkpoint, which makes it subject to the regular stale claim detection from
+// then on. A claim not found in the list is unprepared -- but only if the list
+// was started after the checkpoint entry was first seen here: the API object
+// of a claim is created before its checkpoint entry, hence that list cannot
+// have missed it unless it is gone.
+//
+// Return an error only if the cluster-wide list failed.
+func (m *CheckpointCleanupManager) cleanupNamelessClaims(ctx context.Context, cp *Checkpoint) error {
+	m.legacyMutex.Lock()
+	defer m.legacyMutex.Unlock()
+
+	now := time.Now()
+	nameless := make(PreparedClaimsByUIDV2)
+	for uid, claim := range cp.V2.PreparedClaims {
+		if claim.Name != "" {
+			continue
+		}
+		nameless[uid] = claim
+		if _, exists := m.namelessFirstSeen[uid]; !exists {
+			m.namelessFirstSeen[uid] = now
+		}
+	}
+	for uid := range m.namelessFirstSeen {
+		if _, exists := nameless[uid]; !exists {
+			delete(m.namelessFirstSeen, uid)
+		}
+	}
+
+	if len(nameless) == 0 {
+		// Release memory; this is the steady state.
+		m.uidIndex = nil
+		return nil
+	}
+
+	klog.V(4).Infof("Checkpointed RC cleanup: claims without name in checkpoint: %d (of %d)", len(nameless), len(cp.V2.PreparedClaims))
+
+	if m.uidIndex == nil || time.Since(m.uidIndex.listedAt) >= m.config.Interval {
+		index, err := m.listClaimsByUID(ctx)
+		if err != nil {
+			klog.Warningf("Checkpointed RC cleanup: skip claims without name (retry later): %s", err)
+			return err
+		}
+		m.uidIndex = index
+	}
+
+	found := make(map[string]types.NamespacedName)
+	for uid, cpclaim := range nameless {
+		if nn, exists := m.uidIndex.byUID[uid]; exists {
+			found[uid] = nn
+			continue
+		}
+
+		if !m.uidIndex.listedAt.After(m.namelessFirstSeen[uid]) {
+			klog.FromContext(ctx).V(4).Info("Checkpointed RC cleanup: claim not in cluster-wide claim list, but list predates checkpoint entry (retry later)", logKeyClaimUID, uid)
+			continue
+		}
+
+		klog.FromContext(ctx).V(4).Info("Checkpointed RC cleanup: stale claim: UID not found in cluster-wide claim list", logKeyClaimUID, uid, "checkpointState", cpclaim.CheckpointState)
+		_ = m.unprepare(ctx, uid, cpclaim)
+	}
+
+	if len(found) > 0 {
+		if err := m.backfillClaimNames(ctx, found); err != nil {
+			klog.Warningf("Checkpointed RC cleanup: unable to backfill claim names (retry later): %s", err)
+		}
+	}
+	return nil
+}
+
+// listClaimsByUID() lists all ResourceClaims across all namespaces, in pages.
+func (m *CheckpointCleanupManager) listClaimsByUID(ctx context.Context) (*claimUIDIndex, error) {
+	ctx, cancel := context.WithTimeout(ctx, claimListTimeout)
+	defer cancel()
+
+	p := pager.New(func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+		return m.draclient.ResourceClaims(metav1.NamespaceAll).List(ctx, opts)
+	})
+	p.PageSize = claimListPageSize
+
+	index := &claimUIDIndex{
+		byUID:    make(map[string]types.NamespacedName),
+		listedAt: time.Now(),
+	}
+
+	err := p.EachListItem(ctx, metav1.ListOptions{}, func(obj runtime.Object) error {
+		claim, ok := obj.(*resourcev1.ResourceClaim)
+		if !ok {
+			return fmt.Errorf("unexpected object type in list: %T", obj)
+		}
+		index.byUID[string(claim.UID)] = types.NamespacedName{Namespace: claim.Namespace, Name: claim.Name}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing resource claims: %w", err)
+	}
+
+	klog.V(4).Infof("Checkpointed RC cleanup: listed %d claims cluster-wide in %.3f s", len(index.byUID), time.Since(index.listedAt).Seconds())
+	return index, nil
+}
+
+// backfillClaimNames() writes name and namespace of the given claims into the
+// checkpoint. Entries that meanwhile got removed or got a name are left alone.
+func (m *CheckpointCleanupManager) backfillClaimNames(ctx context.Context, names map[string]types.NamespacedName) error {
+	return m.devicestate.updateCheckpoint(ctx, func(cp *Checkpoint) {
+		for uid, nn := range names {
+			claim, exists := cp.V2.PreparedClaims[uid]
+			if !exists || claim.Name != "" {
+				continue
+			}
+			claim.Name = nn.Name
+			claim.Namespace = nn.Namespace
+			cp.V2.PreparedClaims[uid] = claim
+			klog.FromContext(ctx).Info("Checkpointed RC cleanup: backfilled claim name", logKeyClaim, klog.KRef(claim.Namespace, claim.Name), logKeyClaimUID, uid)
+		}
+	})
+}
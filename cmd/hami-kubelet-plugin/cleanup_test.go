@@ -0,0 +1,49 @@
+/*
+Copyright 2025 The HAMi Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNextDelay(t *testing.T) {
+	m := &CheckpointCleanupManager{config: CheckpointCleanupConfig{Interval: 10 * time.Minute}}
+	errAPI := errors.New("API server unavailable")
+
+	for _, tc := range []struct {
+		err error
+		// Before jitter (up to 10% more).
+		expected time.Duration
+	}{
+		{nil, 10 * time.Minute},
+		{errAPI, 20 * time.Minute},
+		{errAPI, 40 * time.Minute},
+		{errAPI, ResourceClaimCleanupMaxBackoff},
+		{errAPI, ResourceClaimCleanupMaxBackoff},
+		// A successful run resets the backoff.
+		{nil, 10 * time.Minute},
+		{errAPI, 20 * time.Minute},
+	} {
+		delay := m.nextDelay(tc.err)
+		require.GreaterOrEqual(t, delay, tc.expected)
+		require.LessOrEqual(t, delay, tc.expected+tc.expected/10)
+	}
+}
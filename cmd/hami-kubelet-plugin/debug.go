@@ -0,0 +1,132 @@
+/*
+Copyright 2025 The HAMi Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/dump"
+	"k8s.io/klog/v2"
+
+	"github.com/Project-HAMi/k8s-dra-driver/pkg/common"
+)
+
+// The debug server is opt-in (see the debug-address flag): it exposes
+// profiling data, goroutine dumps, the checkpoint and the in-memory device
+// state. Bind it to localhost unless access to the node network is
+// restricted otherwise.
+
+const debugServerShutdownTimeout = 5 * time.Second
+
+type debugServer struct {
+	server *http.Server
+}
+
+// startDebugServer serves
+//
+//   - /debug/pprof/: net/http/pprof profiles
+//   - /debug/goroutines: stack traces of all goroutines (never truncated)
+//   - /debug/checkpoint: the current checkpoint (JSON)
+//   - /debug/state: a snapshot of the in-memory device state
+//
+// Returns nil if no debug address is configured.
+func startDebugServer(config *Config, state *DeviceState) (*debugServer, error) {
+	address := config.flags.debugAddress
+	if address == "" {
+		return nil, nil
+	}
+
+	lis, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("error listening on debug address %s: %w", address, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("GET /debug/goroutines", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if err := common.WriteGoroutineStacks(w); err != nil {
+			klog.Errorf("Error writing goroutine stacks: %v", err)
+		}
+	})
+	mux.HandleFunc("GET /debug/checkpoint", func(w http.ResponseWriter, r *http.Request) {
+		cp, err := state.getCheckpoint(r.Context())
+		if err != nil {
+			http.Error(w, fmt.Sprintf("unable to get checkpoint: %v", err), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(cp); err != nil {
+			klog.Errorf("Error writing checkpoint: %v", err)
+		}
+	})
+	mux.HandleFunc("GET /debug/state", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if err := state.writeSnapshot(w); err != nil {
+			klog.Errorf("Error writing device state snapshot: %v", err)
+		}
+	})
+
+	d := &debugServer{
+		server: &http.Server{
+			Handler:           mux,
+			ReadHeaderTimeout: 10 * time.Second,
+		},
+	}
+	go func() {
+		klog.Infof("Serving debug endpoints on %s", lis.Addr())
+		if err := d.server.Serve(lis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			klog.Errorf("Debug server failed: %v", err)
+		}
+	}()
+	return d, nil
+}
+
+func (d *debugServer) Stop() {
+	if d == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), debugServerShutdownTimeout)
+	defer cancel()
+	if err := d.server.Shutdown(ctx); err != nil {
+		klog.Errorf("Unable to cleanly shutdown debug server: %v", err)
+	}
+}
+
+// writeSnapshot writes the allocatable devices (including unexported
+// properties), grouped by GPU, in a human-readable form.
+func (s *DeviceState) writeSnapshot(w io.Writer) error {
+	s.Lock()
+	defer s.Unlock()
+	_, err := fmt.Fprintf(w, "Allocatable devices: %s\n\nAllocatable devices by GPU minor: %s\n",
+		dump.Pretty(s.allocatable), dump.Pretty(s.perGPUAllocatable))
+	return err
+}
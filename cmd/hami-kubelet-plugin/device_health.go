@@ -19,29 +19,100 @@ package main
 import (
 	"context"
 	"fmt"
+	"maps"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/Project-HAMi/k8s-dra-driver/pkg/featuregates"
+	resourceapi "k8s.io/api/resource/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/klog/v2"
+	"k8s.io/utils/ptr"
 )
 
 const (
 	FullGPUInstanceID uint32 = 0xFFFFFFFF
+
+	// Reasons for marking devices unhealthy other than XIDs.
+	healthReasonGPULost   = "gpu-lost"
+	healthReasonNVMLError = "nvml-error"
 )
 
 // For a MIG device the placement is defined by the 3-tuple <parent UUID, GI, CI>.
 // For a full device the returned 3-tuple is the device's uuid and (FullGPUInstanceID) 0xFFFFFFFF for the other two elements.
 type devicePlacementMap map[string]map[uint32]map[uint32]*AllocatableDevice
 
+// deviceHealthEvent is sent by the health monitor for a device it marked
+// unhealthy.
+type deviceHealthEvent struct {
+	device *AllocatableDevice
+	// XID reported for the device; zero if the device was marked unhealthy
+	// for another reason (e.g. it cannot be reached via NVML anymore).
+	xid uint64
+	// Short, label-like reason, e.g. "xid-48", "clock-throttle" or "gpu-lost".
+	reason string
+	// GPU and compute instance the event was reported for;
+	// FullGPUInstanceID for a full GPU.
+	gi, ci uint32
+	rule   HealthRule
+	time   time.Time
+}
+
+// healthRecord returns the health history record of the event.
+func (e *deviceHealthEvent) healthRecord(health HealthStatus) HealthRecord {
+	r := HealthRecord{
+		Time:   e.time,
+		Health: health,
+		Reason: e.reason,
+		Xid:    e.xid,
+		Action: e.rule.Action,
+	}
+	if e.gi != FullGPUInstanceID {
+		r.GI = ptr.To(e.gi)
+		r.CI = ptr.To(e.ci)
+	}
+	return r
+}
+
+// taint returns the DRA device taint announcing the event.
+func (e *deviceHealthEvent) taint() resourceapi.DeviceTaint {
+	return resourceapi.DeviceTaint{
+		Key:       DriverName + "/" + e.reason,
+		Value:     string(e.rule.Action),
+		Effect:    e.rule.taintEffect(),
+		TimeAdded: &metav1.Time{Time: e.time},
+	}
+}
+
 type nvmlDeviceHealthMonitor struct {
 	nvmllib           nvml.Interface
 	eventSet          nvml.EventSet
-	unhealthy         chan *AllocatableDevice
+	unhealthy         chan *deviceHealthEvent
+	healthy           chan *AllocatableDevice
 	deviceByPlacement devicePlacementMap
-	skippedXids       map[uint64]bool
+	healthPolicy      *healthPolicyStore
 	wg                sync.WaitGroup
+
+	// Devices marked unhealthy, and how they may recover (see
+	// device_health_recovery.go).
+	recoveryInterval time.Duration
+	recoveryCooldown time.Duration
+	recoveryMutex    sync.Mutex
+	unhealthyDevices map[*AllocatableDevice]*unhealthyDevice
+	stopped          bool
+
+	// UUIDs of GPUs lost (e.g. fallen off the bus). Events for these are not
+	// processed anymore. Only accessed by Start() and run().
+	lostGPUs map[string]bool
+	config   *Config
+
+	// Periodic health probes (see device_health_probes.go).
+	probeInterval time.Duration
+	probes        *probeState
 }
 
 func newNvmlDeviceHealthMonitor(config *Config, allocatable AllocatableDevices, nvdevlib *deviceLib) (*nvmlDeviceHealthMonitor, error) {
@@ -55,11 +126,24 @@ func newNvmlDeviceHealthMonitor(config *Config, allocatable AllocatableDevices,
 		_ = nvdevlib.nvmllib.Shutdown()
 	}()
 
+	policyStore, err := newHealthPolicyStore(config.flags.xidPolicyFile, config.flags.additionalXidsToIgnore)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load health policy: %w", err)
+	}
+
 	m := &nvmlDeviceHealthMonitor{
 		nvmllib:           nvdevlib.nvmllib,
-		unhealthy:         make(chan *AllocatableDevice, len(allocatable)),
+		unhealthy:         make(chan *deviceHealthEvent, len(allocatable)),
+		healthy:           make(chan *AllocatableDevice, len(allocatable)),
 		deviceByPlacement: getDevicePlacementMap(allocatable),
-		skippedXids:       xidsToSkip(config.flags.additionalXidsToIgnore),
+		healthPolicy:      policyStore,
+		recoveryInterval:  config.flags.deviceHealthRecoveryInterval,
+		recoveryCooldown:  config.flags.deviceHealthRecoveryCooldown,
+		unhealthyDevices:  make(map[*AllocatableDevice]*unhealthyDevice),
+		lostGPUs:          make(map[string]bool),
+		config:            config,
+		probeInterval:     config.flags.deviceHealthProbeInterval,
+		probes:            newProbeState(),
 	}
 	return m, nil
 }
@@ -86,41 +170,60 @@ func (m *nvmlDeviceHealthMonitor) Start(ctx context.Context) (rerr error) {
 	klog.V(4).Info("registering NVML events for device health monitor")
 	m.registerEventsForDevices()
 
+	// A GPU lost before the last restart of this plugin is either gone for
+	// good (and not allocatable anymore) or has been reset.
+	m.reportLostGPUs(ctx)
+
 	m.wg.Add(1)
 	go func() {
 		defer m.wg.Done()
 		m.run(ctx)
 	}()
 
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		m.healthPolicy.watch(ctx)
+	}()
+
+	if m.recoveryInterval > 0 {
+		m.wg.Add(1)
+		go func() {
+			defer m.wg.Done()
+			m.recoveryLoop(ctx)
+		}()
+	}
+
 	klog.V(4).Info("started device health monitoring")
 	return nil
 }
 
 func (m *nvmlDeviceHealthMonitor) registerEventsForDevices() {
-	eventMask := uint64(nvml.EventTypeXidCriticalError | nvml.EventTypeDoubleBitEccError | nvml.EventTypeSingleBitEccError)
+	eventMask := uint64(nvml.EventTypeXidCriticalError | nvml.EventTypeDoubleBitEccError | nvml.EventTypeSingleBitEccError |
+		nvml.EventTypePState | nvml.EventTypeClock | nvml.EventTypePowerSourceChange)
 
 	for parentUUID, giMap := range m.deviceByPlacement {
 		gpu, ret := m.nvmllib.DeviceGetHandleByUUID(parentUUID)
 		if ret != nvml.SUCCESS {
-			klog.Warningf("Unable to get device handle from UUID[%s]: %v; marking it as unhealthy", parentUUID, ret)
-			m.markAllMigDevicesUnhealthy(giMap)
+			klog.ErrorS(ret, "Unable to get device handle; marking it as unhealthy", logKeyGPUUUID, parentUUID)
+			m.markAllMigDevicesUnhealthy(giMap, healthReasonNVMLError, HealthRule{Action: HealthActionMarkUnhealthy})
 			continue
 		}
 
 		supportedEvents, ret := gpu.GetSupportedEventTypes()
 		if ret != nvml.SUCCESS {
-			klog.Warningf("unable to determine the supported events for %s: %v; marking it as unhealthy", parentUUID, ret)
-			m.markAllMigDevicesUnhealthy(giMap)
+			klog.ErrorS(ret, "Unable to determine the supported events; marking it as unhealthy", logKeyGPUUUID, parentUUID)
+			m.markAllMigDevicesUnhealthy(giMap, healthReasonNVMLError, HealthRule{Action: HealthActionMarkUnhealthy})
 			continue
 		}
 
 		ret = gpu.RegisterEvents(eventMask&supportedEvents, m.eventSet)
 		if ret == nvml.ERROR_NOT_SUPPORTED {
-			klog.Warningf("Device %v is too old to support healthchecking.", parentUUID)
+			klog.InfoS("Device is too old to support healthchecking", logKeyGPUUUID, parentUUID)
 		}
 		if ret != nvml.SUCCESS {
-			klog.Warningf("unable to register events for %s: %v; marking it as unhealthy", parentUUID, ret)
-			m.markAllMigDevicesUnhealthy(giMap)
+			klog.ErrorS(ret, "Unable to register events; marking it as unhealthy", logKeyGPUUUID, parentUUID)
+			m.markAllMigDevicesUnhealthy(giMap, healthReasonNVMLError, HealthRule{Action: HealthActionMarkUnhealthy})
 		}
 	}
 }
@@ -133,6 +236,15 @@ func (m *nvmlDeviceHealthMonitor) Stop() {
 
 	m.wg.Wait()
 
+	m.recoveryMutex.Lock()
+	m.stopped = true
+	for _, u := range m.unhealthyDevices {
+		if u.timer != nil {
+			u.timer.Stop()
+		}
+	}
+	m.recoveryMutex.Unlock()
+
 	if ret := m.eventSet.Free(); ret != nvml.SUCCESS {
 		klog.Warningf("failed to unset events: %v", ret)
 	}
@@ -141,15 +253,23 @@ func (m *nvmlDeviceHealthMonitor) Stop() {
 		klog.Warningf("failed to shutdown NVML: %v", ret)
 	}
 	close(m.unhealthy)
+	close(m.healthy)
 }
 
 func (m *nvmlDeviceHealthMonitor) run(ctx context.Context) {
+	logger := klog.FromContext(ctx)
+	defer backgroundLoops.stop(loopDeviceHealthMonitor)
 	for {
+		// An iteration waits up to 5 s for an event, and may probe the
+		// reachability of the GPUs twice (responsiveness probe, and looking
+		// for lost GPUs), each up to probeResponseTimeout.
+		backgroundLoops.beat(loopDeviceHealthMonitor, 2*probeResponseTimeout+time.Minute)
 		select {
 		case <-ctx.Done():
 			klog.V(6).Info("Stopping event-driven GPU health monitor...")
 			return
 		default:
+			m.runProbes(ctx)
 			event, ret := m.eventSet.Wait(5000) // timeout in 5000 ms.
 			if ret == nvml.ERROR_TIMEOUT {
 				continue
@@ -158,69 +278,193 @@ func (m *nvmlDeviceHealthMonitor) run(ctx context.Context) {
 			// Ref doc: [https://docs.nvidia.com/deploy/nvml-api/group__nvmlEvents.html#group__nvmlEvents_1g9714b0ca9a34c7a7780f87fee16b205c].
 			if ret != nvml.SUCCESS {
 				if ret == nvml.ERROR_GPU_IS_LOST {
-					klog.Warningf("GPU is lost error: %v; Marking all devices as unhealthy", ret)
-					m.markAllDevicesUnhealthy()
+					klog.Warningf("GPU is lost error: %v; looking for lost GPUs", ret)
+					m.handleGPULost(ctx)
 					continue
 				}
 				klog.V(6).Infof("Error waiting for NVML event: %v. Retrying...", ret)
 				continue
 			}
 
-			// TODO: check why other supported types are not considered?
 			eType := event.EventType
-			xid := event.EventData
+			data := event.EventData
 			gi := event.GpuInstanceId
 			ci := event.ComputeInstanceId
-			if eType != nvml.EventTypeXidCriticalError {
-				klog.V(6).Infof("Skipping non-nvmlEventTypeXidCriticalError event: Data=%d, Type=%d, GI=%d, CI=%d", xid, eType, gi, ci)
-				continue
-			}
 
-			if m.skippedXids[xid] {
-				klog.V(6).Infof("Skipping XID event: Data=%d, Type=%d, GI=%d, CI=%d", xid, eType, gi, ci)
+			rule, reason := m.ruleForEvent(event)
+			if rule.Action == HealthActionIgnore {
+				logger.V(6).Info("Skipping event", "eventType", eType, "eventData", data, "gi", gi, "ci", ci)
 				continue
 			}
+			var xid uint64
+			if eType == nvml.EventTypeXidCriticalError {
+				xid = data
+			}
 
-			klog.V(4).Infof("Processing event XID=%d event", xid)
+			logger.V(4).Info("Processing event", "reason", reason, "action", rule.Action)
 			// this seems an extreme action.
 			// should we just log the error and proceed anyway.
 			// TODO: look into how to properly handle this error.
 			eventUUID, ret := event.Device.GetUUID()
 			if ret != nvml.SUCCESS {
-				klog.Warningf("Failed to determine uuid for event %v: %v; Marking all devices as unhealthy.", event, ret)
-				m.markAllDevicesUnhealthy()
+				klog.Warningf("Failed to determine uuid for event %v: %v; looking for lost GPUs", event, ret)
+				m.handleGPULost(ctx)
 				continue
 			}
-			affectedDevice := m.deviceByPlacement.get(eventUUID, gi, ci)
+			if m.lostGPUs[eventUUID] {
+				logger.V(6).Info("Ignoring event for lost GPU", logKeyGPUUUID, eventUUID, "eventType", eType, "eventData", data, "gi", gi, "ci", ci)
+				continue
+			}
+			affectedDevice := m.lookup(eventUUID, gi, ci)
 			if affectedDevice == nil {
-				klog.V(6).Infof("Ignoring event for unexpected device (UUID:%s, GI:%d, CI:%d)", eventUUID, gi, ci)
+				logger.V(6).Info("Ignoring event for unexpected device", logKeyGPUUUID, eventUUID, "gi", gi, "ci", ci)
 				continue
 			}
 
-			klog.V(4).Infof("Sending unhealthy notification for device %s due to event type:%v and event data:%d", affectedDevice.UUID(), eType, xid)
-			m.unhealthy <- affectedDevice
+			dlogger := logger.WithValues(deviceLogValues(affectedDevice)...)
+			if rule.Action == HealthActionRequireReset {
+				dlogger.Error(nil, "Event requires a GPU reset; device stays unhealthy until then", "reason", reason)
+			}
+			m.markUnhealthy(affectedDevice, rule)
+
+			dlogger.V(4).Info("Sending notification for device", "action", rule.Action, "eventType", eType, "eventData", data)
+			m.unhealthy <- &deviceHealthEvent{device: affectedDevice, xid: xid, reason: reason, gi: gi, ci: ci, rule: rule, time: time.Now()}
 		}
 	}
 }
 
-func (m *nvmlDeviceHealthMonitor) Unhealthy() <-chan *AllocatableDevice {
+// clockThrottleReasons are the clock event reasons which indicate the GPU is
+// slowed down by hardware, thermal or power limits.
+const clockThrottleReasons = nvml.ClocksThrottleReasonHwSlowdown |
+	nvml.ClocksThrottleReasonHwThermalSlowdown |
+	nvml.ClocksThrottleReasonHwPowerBrakeSlowdown |
+	nvml.ClocksEventReasonSwThermalSlowdown
+
+// ruleForEvent returns the health policy rule for an NVML event, and a short,
+// label-like reason for it.
+func (m *nvmlDeviceHealthMonitor) ruleForEvent(event nvml.EventData) (HealthRule, string) {
+	policy := m.healthPolicy.get()
+	ignore := HealthRule{Action: HealthActionIgnore}
+
+	switch event.EventType {
+	case nvml.EventTypeXidCriticalError:
+		return policy.ruleFor(event.EventData), fmt.Sprintf("xid-%d", event.EventData)
+	case nvml.EventTypeDoubleBitEccError:
+		return policy.events.DoubleBitEcc.HealthRule, "double-bit-ecc"
+	case nvml.EventTypeSingleBitEccError:
+		rule := policy.events.SingleBitEcc
+		if rule.Threshold > 0 {
+			count, ret := event.Device.GetTotalEccErrors(nvml.MEMORY_ERROR_TYPE_CORRECTED, nvml.VOLATILE_ECC)
+			if ret != nvml.SUCCESS || count < rule.Threshold {
+				return ignore, ""
+			}
+		}
+		return rule.HealthRule, "single-bit-ecc"
+	case nvml.EventTypePState:
+		return policy.events.PState.HealthRule, "pstate"
+	case nvml.EventTypeClock:
+		reasons, ret := event.Device.GetCurrentClocksEventReasons()
+		if ret != nvml.SUCCESS || reasons&clockThrottleReasons == 0 {
+			return ignore, ""
+		}
+		return policy.events.ClockThrottle.HealthRule, "clock-throttle"
+	case nvml.EventTypePowerSourceChange:
+		return policy.events.PowerSource.HealthRule, "power-source"
+	}
+	return ignore, ""
+}
+
+func (m *nvmlDeviceHealthMonitor) Unhealthy() <-chan *deviceHealthEvent {
 	return m.unhealthy
 }
 
-func (m *nvmlDeviceHealthMonitor) markAllDevicesUnhealthy() {
+func (m *nvmlDeviceHealthMonitor) Healthy() <-chan *AllocatableDevice {
+	return m.healthy
+}
+
+// handleGPULost marks the GPUs that cannot be reached via NVML anymore (and
+// all devices on them) unhealthy until reset, and reports them in a node
+// condition. NVML does not tell which GPU was lost: look at all of them. GPUs
+// not answering in time, or failing otherwise (possibly transiently), are
+// marked unhealthy (as unresponsive) but not as lost. If no GPU can be
+// identified at all, mark all devices unhealthy.
+func (m *nvmlDeviceHealthMonitor) handleGPULost(ctx context.Context) {
+	var uuids []string
+	for uuid := range m.deviceByPlacement {
+		if !m.lostGPUs[uuid] && m.probes.unresponsive[uuid] == nil {
+			uuids = append(uuids, uuid)
+		}
+	}
+
+	lost, failed := 0, 0
+	for parentUUID, p := range m.probeGPUsReachable(uuids) {
+		giMap := m.deviceByPlacement[parentUUID]
+		switch {
+		case !p.returned():
+			klog.FromContext(ctx).Info("GPU did not answer an NVML query in time; marking all devices on it as unhealthy", logKeyGPUUUID, parentUUID, "timeout", probeResponseTimeout)
+			m.markAllMigDevicesUnhealthy(giMap, healthReasonUnresponsive, HealthRule{Action: HealthActionMarkUnhealthy})
+			failed++
+		case p.lost():
+			klog.FromContext(ctx).Error(p.err, "GPU lost; marking it and all devices on it as unhealthy", logKeyGPUUUID, parentUUID)
+			m.lostGPUs[parentUUID] = true
+			m.markAllMigDevicesUnhealthy(giMap, healthReasonGPULost, HealthRule{Action: HealthActionRequireReset})
+			lost++
+		case p.err != nil:
+			klog.FromContext(ctx).Error(p.err, "GPU failed an NVML query; marking all devices on it as unhealthy", logKeyGPUUUID, parentUUID)
+			m.markAllMigDevicesUnhealthy(giMap, healthReasonUnresponsive, HealthRule{Action: HealthActionMarkUnhealthy})
+			failed++
+		}
+	}
+
+	if lost == 0 && failed == 0 {
+		klog.Warningf("Unable to identify lost GPU; marking all devices as unhealthy")
+		m.markAllDevicesUnhealthy(healthReasonNVMLError, HealthRule{Action: HealthActionMarkUnhealthy})
+		return
+	}
+	if lost > 0 {
+		m.reportLostGPUs(ctx)
+	}
+}
+
+// probeGPUReachable returns an error if the GPU does not answer NVML queries.
+// The error wraps nvml.ERROR_GPU_IS_LOST if NVML reports the GPU as lost; other
+// errors may be transient.
+func (m *nvmlDeviceHealthMonitor) probeGPUReachable(uuid string) error {
+	gpu, ret := m.nvmllib.DeviceGetHandleByUUID(uuid)
+	if ret != nvml.SUCCESS {
+		return fmt.Errorf("error getting device handle: %w", ret)
+	}
+	if _, ret := gpu.GetMemoryInfo(); ret == nvml.ERROR_GPU_IS_LOST {
+		return fmt.Errorf("error getting memory info: %w", ret)
+	}
+	return nil
+}
+
+// reportLostGPUs updates the NodeConditionGPULost node condition. Errors are
+// logged only: the condition is informational.
+func (m *nvmlDeviceHealthMonitor) reportLostGPUs(ctx context.Context) {
+	lost := slices.Sorted(maps.Keys(m.lostGPUs))
+	if err := setGPULostCondition(ctx, m.config, lost); err != nil {
+		klog.Warningf("Unable to set node condition %s: %s", NodeConditionGPULost, err)
+	}
+}
+
+func (m *nvmlDeviceHealthMonitor) markAllDevicesUnhealthy(reason string, rule HealthRule) {
 	for _, giMap := range m.deviceByPlacement {
-		m.markAllMigDevicesUnhealthy(giMap)
+		m.markAllMigDevicesUnhealthy(giMap, reason, rule)
 	}
 }
 
-// markAllMigDevicesUnhealthy is a helper function to mark every mig device under a parent as unhealthy.
-func (m *nvmlDeviceHealthMonitor) markAllMigDevicesUnhealthy(giMap map[uint32]map[uint32]*AllocatableDevice) {
-	for _, ciMap := range giMap {
-		for _, dev := range ciMap {
+// markAllMigDevicesUnhealthy is a helper function to mark every device under a
+// parent (the full GPU, HAMi and MIG devices) as unhealthy.
+func (m *nvmlDeviceHealthMonitor) markAllMigDevicesUnhealthy(giMap map[uint32]map[uint32]*AllocatableDevice, reason string, rule HealthRule) {
+	for gi, ciMap := range giMap {
+		for ci, dev := range ciMap {
+			m.markUnhealthy(dev, rule)
 			// Non-blocking send to avoid deadlocks if channel is full.
 			select {
-			case m.unhealthy <- dev:
-				klog.V(6).Infof("Marked device %s as unhealthy", dev.UUID())
+			case m.unhealthy <- &deviceHealthEvent{device: dev, reason: reason, gi: gi, ci: ci, rule: rule, time: time.Now()}:
+				klog.V(6).InfoS("Marked device as unhealthy", deviceLogValues(dev)...)
 			// TODO: The non-blocking send protects the health-monitor goroutine from deadlocks,
 			// but dropping an unhealthy notification means the device's health transition may
 			// never reach the consumer. Consider follow-up improvements:
@@ -228,7 +472,7 @@ func (m *nvmlDeviceHealthMonitor) markAllMigDevicesUnhealthy(giMap map[uint32]ma
 			//   - introduce a special "all devices unhealthy" message when bulk updates occur;
 			//   - or revisit whether blocking briefly here is acceptable.
 			default:
-				klog.Errorf("Unhealthy channel full. Dropping unhealthy notification for device %s", dev.UUID())
+				klog.ErrorS(nil, "Unhealthy channel full. Dropping unhealthy notification for device", deviceLogValues(dev)...)
 			}
 		}
 	}
@@ -236,9 +480,10 @@ func (m *nvmlDeviceHealthMonitor) markAllMigDevicesUnhealthy(giMap map[uint32]ma
 
 // The purpose of this function is to allow for a O(1) lookup of
 // AllocatableDevice by ([parent]UUID, GI, CI) when processing health events. It
-// currently assumes that this is constant for the lifetime of the healthchecker
-// which does not hold for Dynamic MIG. This will have to be resolved once we
-// support device health checking with dynamic MIG.
+// assumes that this is constant for the lifetime of the healthchecker, which
+// does not hold for Dynamic MIG: there, abstract MIG devices are not part of
+// the map, and health events for MIG devices are attributed to the parent GPU
+// (see lookup()).
 func getDevicePlacementMap(allocatable AllocatableDevices) devicePlacementMap {
 	placementMap := make(devicePlacementMap)
 
@@ -247,7 +492,7 @@ func getDevicePlacementMap(allocatable AllocatableDevices) devicePlacementMap {
 		var giID, ciID uint32
 
 		switch d.Type() {
-		case GpuDeviceType:
+		case GpuDeviceType, HAMiGpuDeviceType:
 			parentUUID = d.UUID()
 			if parentUUID == "" {
 				continue
@@ -276,6 +521,17 @@ func getDevicePlacementMap(allocatable AllocatableDevices) devicePlacementMap {
 	return placementMap
 }
 
+// lookup returns the allocatable device affected by a health event, or nil.
+func (m *nvmlDeviceHealthMonitor) lookup(uuid string, gi, ci uint32) *AllocatableDevice {
+	if d := m.deviceByPlacement.get(uuid, gi, ci); d != nil {
+		return d
+	}
+	if featuregates.Enabled(featuregates.DynamicMIG) {
+		return m.deviceByPlacement.get(uuid, FullGPUInstanceID, FullGPUInstanceID)
+	}
+	return nil
+}
+
 func (p devicePlacementMap) addDevice(parentUUID string, giID uint32, ciID uint32, d *AllocatableDevice) {
 	if _, ok := p[parentUUID]; !ok {
 		p[parentUUID] = make(map[uint32]map[uint32]*AllocatableDevice)
@@ -302,7 +558,6 @@ func (p devicePlacementMap) get(uuid string, gi, ci uint32) *AllocatableDevice {
 // getAdditionalXids returns a list of additional Xids to skip from the specified string.
 // The input is treaded as a comma-separated string and all valid uint64 values are considered as Xid values.
 // Invalid values nare ignored.
-// TODO: add list of EXPLICIT XIDs from [https://github.com/NVIDIA/k8s-device-plugin/pull/1443].
 func getAdditionalXids(input string) []uint64 {
 	if input == "" {
 		return nil
@@ -325,27 +580,3 @@ func getAdditionalXids(input string) []uint64 {
 
 	return additionalXids
 }
-
-func xidsToSkip(additionalXids string) map[uint64]bool {
-	// Add the list of hardcoded disabled (ignored) XIDs:
-	// http://docs.nvidia.com/deploy/xid-errors/index.html#topic_4
-	// Application errors: the GPU should still be healthy.
-	ignoredXids := []uint64{
-		13,  // Graphics Engine Exception
-		31,  // GPU memory page fault
-		43,  // GPU stopped processing
-		45,  // Preemptive cleanup, due to previous errors
-		68,  // Video processor exception
-		109, // Context Switch Timeout Error
-	}
-
-	skippedXids := make(map[uint64]bool)
-	for _, id := range ignoredXids {
-		skippedXids[id] = true
-	}
-
-	for _, additionalXid := range getAdditionalXids(additionalXids) {
-		skippedXids[additionalXid] = true
-	}
-	return skippedXids
-}
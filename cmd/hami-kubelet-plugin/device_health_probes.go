@@ -0,0 +1,355 @@
+/*
+Copyright 2025 The HAMi Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"k8s.io/klog/v2"
+)
+
+// Not all failures produce NVML events. The health probes periodically query
+// NVML for them, and mark all devices of an affected GPU unhealthy (or
+// degraded) according to the rule of the probe in the health policy. Probes
+// run in the event loop of the health monitor (see run()), between two waits
+// for NVML events.
+
+const (
+	// Time after which a GPU not answering a probe is considered
+	// unresponsive.
+	probeResponseTimeout = 30 * time.Second
+
+	healthReasonUnresponsive = "unresponsive"
+)
+
+// healthProbe is a periodic check of each GPU. check returns a reason if the
+// GPU fails the probe, and an empty string otherwise.
+type healthProbe struct {
+	name  string
+	rule  func(*healthPolicy) *ProbeRule
+	check func(m *nvmlDeviceHealthMonitor, uuid string, gpu nvml.Device, rule *ProbeRule) (string, error)
+}
+
+var healthProbes = []healthProbe{
+	{
+		name:  "retiredPages",
+		rule:  func(p *healthPolicy) *ProbeRule { return p.probes.RetiredPages },
+		check: (*nvmlDeviceHealthMonitor).probeRetiredPages,
+	},
+	{
+		name:  "remappedRows",
+		rule:  func(p *healthPolicy) *ProbeRule { return p.probes.RemappedRows },
+		check: (*nvmlDeviceHealthMonitor).probeRemappedRows,
+	},
+	{
+		name:  "eccErrors",
+		rule:  func(p *healthPolicy) *ProbeRule { return p.probes.EccErrors },
+		check: (*nvmlDeviceHealthMonitor).probeEccErrors,
+	},
+	{
+		name:  "pcieReplay",
+		rule:  func(p *healthPolicy) *ProbeRule { return p.probes.PcieReplay },
+		check: (*nvmlDeviceHealthMonitor).probePcieReplay,
+	},
+	{
+		name:  "temperature",
+		rule:  func(p *healthPolicy) *ProbeRule { return p.probes.Temperature },
+		check: (*nvmlDeviceHealthMonitor).probeTemperature,
+	},
+}
+
+// probeState is what the health probes remember between runs. Only accessed
+// by run().
+type probeState struct {
+	// Time of the last run, by probe name.
+	lastRun map[string]time.Time
+	// Last counter value, by probe name and GPU UUID.
+	counters map[string]map[string]uint64
+	// GPUs which did not answer the responsiveness probe; the channel is
+	// closed once the pending NVML query returns.
+	unresponsive map[string]chan struct{}
+}
+
+func newProbeState() *probeState {
+	return &probeState{
+		lastRun:      make(map[string]time.Time),
+		counters:     make(map[string]map[string]uint64),
+		unresponsive: make(map[string]chan struct{}),
+	}
+}
+
+// due returns true if a probe with the given interval is due, and records it
+// as run.
+func (s *probeState) due(name string, interval time.Duration) bool {
+	if time.Since(s.lastRun[name]) < interval {
+		return false
+	}
+	s.lastRun[name] = time.Now()
+	return true
+}
+
+// increase records the counter value of a GPU, and returns its increase since
+// the last call; zero upon the first call.
+func (s *probeState) increase(name, uuid string, value uint64) uint64 {
+	if s.counters[name] == nil {
+		s.counters[name] = make(map[string]uint64)
+	}
+	last, seen := s.counters[name][uuid]
+	s.counters[name][uuid] = value
+	if !seen || value < last {
+		return 0
+	}
+	return value - last
+}
+
+// runProbes runs the health probes which are due.
+func (m *nvmlDeviceHealthMonitor) runProbes(ctx context.Context) {
+	if m.probeInterval <= 0 {
+		return
+	}
+	policy := m.healthPolicy.get()
+
+	if rule := policy.probes.Responsive; rule.Action != HealthActionIgnore && m.probes.due("responsive", m.probeIntervalFor(rule)) {
+		if m.probeResponsive(ctx, rule) {
+			// Lost GPUs were handled; probe the remaining GPUs next time.
+			return
+		}
+	}
+
+	for _, p := range healthProbes {
+		rule := p.rule(policy)
+		if rule.Action == HealthActionIgnore || !m.probes.due(p.name, m.probeIntervalFor(rule)) {
+			continue
+		}
+		klog.V(6).Infof("Running health probe %s", p.name)
+		for uuid, giMap := range m.deviceByPlacement {
+			if m.lostGPUs[uuid] || m.probes.unresponsive[uuid] != nil {
+				continue
+			}
+			gpu, ret := m.nvmllib.DeviceGetHandleByUUID(uuid)
+			if ret != nvml.SUCCESS {
+				klog.V(4).InfoS("Health probe: unable to get device handle", "probe", p.name, logKeyGPUUUID, uuid, "err", ret)
+				continue
+			}
+			reason, err := p.check(m, uuid, gpu, rule)
+			if err != nil {
+				klog.V(4).InfoS("Health probe: query failed", "probe", p.name, logKeyGPUUUID, uuid, "err", err)
+				continue
+			}
+			if reason == "" {
+				continue
+			}
+			klog.InfoS("GPU failed health probe", "probe", p.name, logKeyGPUUUID, uuid, "reason", reason, "action", rule.Action)
+			m.markAllMigDevicesUnhealthy(giMap, reason, rule.HealthRule)
+		}
+	}
+}
+
+func (m *nvmlDeviceHealthMonitor) probeIntervalFor(rule *ProbeRule) time.Duration {
+	return max(rule.Interval.Duration, m.probeInterval)
+}
+
+// reachabilityProbe is a pending or completed probeGPUReachable() call.
+type reachabilityProbe struct {
+	// Closed once the call returned.
+	done chan struct{}
+	// Only valid once done is closed.
+	err error
+}
+
+func (p *reachabilityProbe) returned() bool {
+	select {
+	case <-p.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// lost returns true if the call returned, reporting the GPU as lost.
+func (p *reachabilityProbe) lost() bool {
+	return p.returned() && errors.Is(p.err, nvml.ERROR_GPU_IS_LOST)
+}
+
+// probeGPUsReachable probes the GPUs concurrently, and waits for the probes up
+// to probeResponseTimeout overall: a stuck GPU does not delay the others.
+// Probes which did not return in time keep running in the background.
+func (m *nvmlDeviceHealthMonitor) probeGPUsReachable(uuids []string) map[string]*reachabilityProbe {
+	probes := make(map[string]*reachabilityProbe, len(uuids))
+	for _, uuid := range uuids {
+		p := &reachabilityProbe{done: make(chan struct{})}
+		probes[uuid] = p
+		go func() {
+			defer close(p.done)
+			p.err = m.probeGPUReachable(uuid)
+		}()
+	}
+
+	deadline := time.After(probeResponseTimeout)
+	for _, p := range probes {
+		select {
+		case <-p.done:
+		case <-deadline:
+			return probes
+		}
+	}
+	return probes
+}
+
+// probeResponsive checks that all GPUs answer an NVML query in time. A query
+// which does not return in time keeps the GPU unresponsive (and its devices
+// marked per the rule) until it eventually returns; a query which fails
+// otherwise marks the devices per the rule, and is retried by the next probe.
+// Return true if lost GPUs were found (and handled).
+func (m *nvmlDeviceHealthMonitor) probeResponsive(ctx context.Context, rule *ProbeRule) bool {
+	var uuids []string
+	for uuid := range m.deviceByPlacement {
+		if !m.lostGPUs[uuid] && m.probes.unresponsive[uuid] == nil {
+			uuids = append(uuids, uuid)
+		}
+	}
+
+	lost := false
+	for uuid, p := range m.probeGPUsReachable(uuids) {
+		switch {
+		case !p.returned():
+			m.probes.unresponsive[uuid] = p.done
+		case p.lost():
+			lost = true
+		case p.err != nil:
+			klog.InfoS("GPU failed an NVML query", logKeyGPUUUID, uuid, "err", p.err, "action", rule.Action)
+			m.markAllMigDevicesUnhealthy(m.deviceByPlacement[uuid], healthReasonUnresponsive, rule.HealthRule)
+		}
+	}
+
+	for uuid, done := range m.probes.unresponsive {
+		select {
+		case <-done:
+			klog.InfoS("GPU is responsive again", logKeyGPUUUID, uuid)
+			delete(m.probes.unresponsive, uuid)
+		default:
+			klog.InfoS("GPU did not answer an NVML query in time", logKeyGPUUUID, uuid, "timeout", probeResponseTimeout, "action", rule.Action)
+			m.markAllMigDevicesUnhealthy(m.deviceByPlacement[uuid], healthReasonUnresponsive, rule.HealthRule)
+		}
+	}
+
+	if lost {
+		m.handleGPULost(ctx)
+	}
+	return lost
+}
+
+func (m *nvmlDeviceHealthMonitor) probeRetiredPages(uuid string, gpu nvml.Device, rule *ProbeRule) (string, error) {
+	pending, ret := gpu.GetRetiredPagesPendingStatus()
+	switch {
+	case ret == nvml.ERROR_NOT_SUPPORTED:
+		return "", nil
+	case ret != nvml.SUCCESS:
+		return "", fmt.Errorf("error getting retired pages pending status: %v", ret)
+	case pending == nvml.FEATURE_ENABLED:
+		return "retired-pages-pending", nil
+	}
+
+	if rule.Threshold == 0 {
+		return "", nil
+	}
+	var retired uint64
+	for _, cause := range []nvml.PageRetirementCause{nvml.PAGE_RETIREMENT_CAUSE_MULTIPLE_SINGLE_BIT_ECC_ERRORS, nvml.PAGE_RETIREMENT_CAUSE_DOUBLE_BIT_ECC_ERROR} {
+		pages, ret := gpu.GetRetiredPages(cause)
+		if ret != nvml.SUCCESS {
+			return "", fmt.Errorf("error getting retired pages: %v", ret)
+		}
+		retired += uint64(len(pages))
+	}
+	if retired >= rule.Threshold {
+		return "retired-pages-exhausted", nil
+	}
+	return "", nil
+}
+
+func (m *nvmlDeviceHealthMonitor) probeRemappedRows(uuid string, gpu nvml.Device, rule *ProbeRule) (string, error) {
+	_, _, pending, failed, ret := gpu.GetRemappedRows()
+	switch {
+	case ret == nvml.ERROR_NOT_SUPPORTED:
+		return "", nil
+	case ret != nvml.SUCCESS:
+		return "", fmt.Errorf("error getting remapped rows: %v", ret)
+	case failed:
+		return "row-remapping-failed", nil
+	case pending:
+		return "row-remapping-pending", nil
+	}
+	return "", nil
+}
+
+func (m *nvmlDeviceHealthMonitor) probeEccErrors(uuid string, gpu nvml.Device, rule *ProbeRule) (string, error) {
+	count, ret := gpu.GetTotalEccErrors(nvml.MEMORY_ERROR_TYPE_UNCORRECTED, nvml.VOLATILE_ECC)
+	switch {
+	case ret == nvml.ERROR_NOT_SUPPORTED:
+		return "", nil
+	case ret != nvml.SUCCESS:
+		return "", fmt.Errorf("error getting ECC error count: %v", ret)
+	}
+	if increase := m.probes.increase("eccErrors", uuid, count); increase > 0 && increase >= rule.Threshold {
+		return "ecc-errors", nil
+	}
+	return "", nil
+}
+
+func (m *nvmlDeviceHealthMonitor) probePcieReplay(uuid string, gpu nvml.Device, rule *ProbeRule) (string, error) {
+	count, ret := gpu.GetPcieReplayCounter()
+	switch {
+	case ret == nvml.ERROR_NOT_SUPPORTED:
+		return "", nil
+	case ret != nvml.SUCCESS:
+		return "", fmt.Errorf("error getting PCIe replay counter: %v", ret)
+	}
+	if increase := m.probes.increase("pcieReplay", uuid, uint64(count)); increase > 0 && increase >= rule.Threshold {
+		return "pcie-replay", nil
+	}
+	return "", nil
+}
+
+func (m *nvmlDeviceHealthMonitor) probeTemperature(uuid string, gpu nvml.Device, rule *ProbeRule) (string, error) {
+	temperature, ret := gpu.GetTemperature(nvml.TEMPERATURE_GPU)
+	switch {
+	case ret == nvml.ERROR_NOT_SUPPORTED:
+		return "", nil
+	case ret != nvml.SUCCESS:
+		return "", fmt.Errorf("error getting temperature: %v", ret)
+	}
+
+	threshold := rule.Threshold
+	if threshold == 0 {
+		slowdown, ret := gpu.GetTemperatureThreshold(nvml.TEMPERATURE_THRESHOLD_SLOWDOWN)
+		switch {
+		case ret == nvml.ERROR_NOT_SUPPORTED:
+			return "", nil
+		case ret != nvml.SUCCESS:
+			return "", fmt.Errorf("error getting slowdown temperature: %v", ret)
+		}
+		threshold = uint64(slowdown)
+	}
+	if uint64(temperature) >= threshold {
+		return "temperature", nil
+	}
+	return "", nil
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2025 The HAMi Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProbeStateIncrease(t *testing.T) {
+	s := newProbeState()
+	for _, tc := range []struct {
+		name     string
+		uuid     string
+		value    uint64
+		expected uint64
+	}{
+		{"pcieReplay", "GPU-0", 10, 0},
+		{"pcieReplay", "GPU-0", 15, 5},
+		{"pcieReplay", "GPU-0", 15, 0},
+		// Counters are tracked per probe and GPU.
+		{"pcieReplay", "GPU-1", 100, 0},
+		{"eccErrors", "GPU-0", 3, 0},
+		{"pcieReplay", "GPU-1", 101, 1},
+		// A counter which was reset (e.g. upon a GPU reset) starts over.
+		{"pcieReplay", "GPU-0", 2, 0},
+		{"pcieReplay", "GPU-0", 4, 2},
+	} {
+		require.Equal(t, tc.expected, s.increase(tc.name, tc.uuid, tc.value), "%s %s %d", tc.name, tc.uuid, tc.value)
+	}
+}
+
+func TestProbeStateDue(t *testing.T) {
+	s := newProbeState()
+	for _, tc := range []struct {
+		name     string
+		interval time.Duration
+		expected bool
+	}{
+		// Never run before.
+		{"temperature", time.Hour, true},
+		{"temperature", time.Hour, false},
+		{"pcieReplay", time.Hour, true},
+		{"temperature", 0, true},
+		{"temperature", time.Hour, false},
+	} {
+		require.Equal(t, tc.expected, s.due(tc.name, tc.interval), "%s %s", tc.name, tc.interval)
+	}
+}
+
+func TestReachabilityProbeLost(t *testing.T) {
+	returned := func(err error) *reachabilityProbe {
+		p := &reachabilityProbe{done: make(chan struct{}), err: err}
+		close(p.done)
+		return p
+	}
+	for _, tc := range []struct {
+		name     string
+		probe    *reachabilityProbe
+		expected bool
+	}{
+		{"reachable", returned(nil), false},
+		{"lost", returned(fmt.Errorf("error getting device handle: %w", nvml.ERROR_GPU_IS_LOST)), true},
+		{"transient error", returned(fmt.Errorf("error getting device handle: %w", nvml.ERROR_UNKNOWN)), false},
+		{"pending", &reachabilityProbe{done: make(chan struct{})}, false},
+	} {
+		require.Equal(t, tc.expected, tc.probe.lost(), tc.name)
+	}
+}
@@ -0,0 +1,222 @@
+/*
+Copyright 2025 The HAMi Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"k8s.io/klog/v2"
+)
+
+// unhealthyDevice is what the health monitor knows about a device it marked
+// unhealthy or degraded.
+//
+// A device recovers (is marked healthy, and no longer degraded, again) when
+//   - it was not marked unhealthy by a RequireReset rule, and
+//   - no health event was seen for it during the cool-down period, or its
+//     MarkUnhealthyFor period is over, and
+//   - it passes probeDevice().
+type unhealthyDevice struct {
+	// Time of the last health event for this device.
+	lastEvent     time.Time
+	requiresReset bool
+	// Recovery timer of a MarkUnhealthyFor rule.
+	timer *time.Timer
+	// Volatile uncorrected ECC error count of the (parent) GPU when the
+	// device was first marked unhealthy; nil if not supported.
+	eccBaseline *uint64
+}
+
+// markUnhealthy records a health event for the device, including one which only
+// degrades the device. Call it before sending the device to the unhealthy
+// channel.
+func (m *nvmlDeviceHealthMonitor) markUnhealthy(d *AllocatableDevice, rule HealthRule) {
+	ecc := m.uncorrectedEccErrors(d)
+
+	m.recoveryMutex.Lock()
+	defer m.recoveryMutex.Unlock()
+
+	u, exists := m.unhealthyDevices[d]
+	if !exists {
+		u = &unhealthyDevice{eccBaseline: ecc}
+		m.unhealthyDevices[d] = u
+	}
+	u.lastEvent = time.Now()
+
+	if rule.Action == HealthActionRequireReset {
+		u.requiresReset = true
+	}
+
+	switch {
+	case u.requiresReset || rule.Action != HealthActionMarkUnhealthyFor:
+		// A later event (of another kind) overrides a pending timed recovery.
+		if u.timer != nil {
+			u.timer.Stop()
+			u.timer = nil
+		}
+	case u.timer != nil:
+		// A later event extends the time the device is unhealthy.
+		u.timer.Reset(rule.Duration.Duration)
+	case !exists:
+		u.timer = time.AfterFunc(rule.Duration.Duration, func() {
+			m.tryRecover(d, "unhealthy period is over")
+		})
+	}
+}
+
+// recoveryLoop periodically re-probes unhealthy devices past their cool-down
+// period until the context is canceled.
+func (m *nvmlDeviceHealthMonitor) recoveryLoop(ctx context.Context) {
+	klog.V(4).Infof("Starting device health recovery loop (interval: %s, cool-down: %s)", m.recoveryInterval, m.recoveryCooldown)
+	ticker := time.NewTicker(m.recoveryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		var candidates []*AllocatableDevice
+		m.recoveryMutex.Lock()
+		for d, u := range m.unhealthyDevices {
+			if u.requiresReset || u.timer != nil || time.Since(u.lastEvent) < m.recoveryCooldown {
+				continue
+			}
+			candidates = append(candidates, d)
+		}
+		m.recoveryMutex.Unlock()
+
+		for _, d := range candidates {
+			m.tryRecover(d, "cool-down period is over")
+		}
+	}
+}
+
+// tryRecover probes the device and, if it passes, forgets about the device
+// and sends it to the healthy channel.
+func (m *nvmlDeviceHealthMonitor) tryRecover(d *AllocatableDevice, reason string) {
+	m.recoveryMutex.Lock()
+	u, exists := m.unhealthyDevices[d]
+	var lastEvent time.Time
+	if exists {
+		u.timer = nil
+		lastEvent = u.lastEvent
+	}
+	m.recoveryMutex.Unlock()
+	if !exists {
+		return
+	}
+
+	// NVML calls can be slow: do not hold the lock while probing.
+	if err := m.probeDevice(d, u.eccBaseline); err != nil {
+		klog.InfoS("Device stays unhealthy or degraded: probe failed", append(deviceLogValues(d), "reason", reason, "err", err)...)
+		return
+	}
+
+	m.recoveryMutex.Lock()
+	defer m.recoveryMutex.Unlock()
+	// Bail out if a health event was seen for the device while probing.
+	if m.stopped || m.unhealthyDevices[d] != u || !u.lastEvent.Equal(lastEvent) {
+		return
+	}
+	delete(m.unhealthyDevices, d)
+
+	select {
+	case m.healthy <- d:
+		klog.InfoS("Device recovered; marking it as healthy", append(deviceLogValues(d), "reason", reason)...)
+	default:
+		klog.ErrorS(nil, "Healthy channel full; dropping healthy notification", deviceLogValues(d)...)
+	}
+}
+
+// probeDevice returns an error if the device does not look usable: the device
+// (and its parent GPU) must be reachable via NVML and answer a lightweight
+// query; the parent GPU must neither have new uncorrected ECC errors nor
+// memory pages pending retirement or rows pending remapping (these take effect
+// only upon GPU reset).
+func (m *nvmlDeviceHealthMonitor) probeDevice(d *AllocatableDevice, eccBaseline *uint64) error {
+	device, ret := m.nvmllib.DeviceGetHandleByUUID(d.UUID())
+	if ret != nvml.SUCCESS {
+		return fmt.Errorf("error getting device handle: %v", ret)
+	}
+	if _, ret := device.GetMemoryInfo(); ret != nvml.SUCCESS {
+		return fmt.Errorf("error getting memory info: %v", ret)
+	}
+
+	gpu, ret := m.nvmllib.DeviceGetHandleByUUID(parentUUID(d))
+	if ret != nvml.SUCCESS {
+		return fmt.Errorf("error getting GPU handle: %v", ret)
+	}
+
+	if eccBaseline != nil {
+		count, ret := gpu.GetTotalEccErrors(nvml.MEMORY_ERROR_TYPE_UNCORRECTED, nvml.VOLATILE_ECC)
+		if ret != nvml.SUCCESS {
+			return fmt.Errorf("error getting ECC error count: %v", ret)
+		}
+		if count > *eccBaseline {
+			return fmt.Errorf("uncorrected ECC errors increased from %d to %d", *eccBaseline, count)
+		}
+	}
+
+	pending, ret := gpu.GetRetiredPagesPendingStatus()
+	switch {
+	case ret == nvml.ERROR_NOT_SUPPORTED:
+	case ret != nvml.SUCCESS:
+		return fmt.Errorf("error getting retired pages pending status: %v", ret)
+	case pending == nvml.FEATURE_ENABLED:
+		return fmt.Errorf("memory pages pending retirement")
+	}
+
+	_, _, remapPending, remapFailed, ret := gpu.GetRemappedRows()
+	switch {
+	case ret == nvml.ERROR_NOT_SUPPORTED:
+	case ret != nvml.SUCCESS:
+		return fmt.Errorf("error getting remapped rows: %v", ret)
+	case remapFailed:
+		return fmt.Errorf("row remapping failed")
+	case remapPending:
+		return fmt.Errorf("rows pending remapping")
+	}
+
+	return nil
+}
+
+// uncorrectedEccErrors returns the volatile uncorrected ECC error count of the
+// (parent) GPU of the device; nil if it cannot be determined.
+func (m *nvmlDeviceHealthMonitor) uncorrectedEccErrors(d *AllocatableDevice) *uint64 {
+	gpu, ret := m.nvmllib.DeviceGetHandleByUUID(parentUUID(d))
+	if ret != nvml.SUCCESS {
+		return nil
+	}
+	count, ret := gpu.GetTotalEccErrors(nvml.MEMORY_ERROR_TYPE_UNCORRECTED, nvml.VOLATILE_ECC)
+	if ret != nvml.SUCCESS {
+		return nil
+	}
+	return &count
+}
+
+func parentUUID(d *AllocatableDevice) string {
+	if d.MigStatic != nil {
+		return d.MigStatic.parent.UUID
+	}
+	return d.UUID()
+}
@@ -25,6 +25,7 @@ import (
 	"sync"
 	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	resourceapi "k8s.io/api/resource/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/dynamic-resource-allocation/kubeletplugin"
@@ -78,7 +79,7 @@ func NewDeviceState(ctx context.Context, config *Config) (*DeviceState, error) {
 	devRoot := containerDriverRoot.getDevRoot()
 	klog.Infof("Using devRoot=%v", devRoot)
 
-	nvdevlib, err := newDeviceLib(containerDriverRoot)
+	nvdevlib, err := newDeviceLib(containerDriverRoot, config.flags.dryRun, config.auditLog)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create device library: %w", err)
 	}
@@ -106,6 +107,7 @@ func NewDeviceState(ctx context.Context, config *Config) (*DeviceState, error) {
 		WithTargetDriverRoot(hostDriverRoot),
 		WithNVIDIACDIHookPath(config.flags.nvidiaCDIHookPath),
 		WithCDIRoot(config.flags.cdiRoot),
+		WithDryRun(config.flags.dryRun),
 		WithLogger(cdilogger),
 	)
 	if err != nil {
@@ -147,12 +149,12 @@ func NewDeviceState(ctx context.Context, config *Config) (*DeviceState, error) {
 		}
 	}
 
-	checkpointManager, err := checkpointmanager.NewCheckpointManager(config.DriverPluginPath())
+	checkpointManager, err := checkpointmanager.NewCheckpointManager(config.DriverStatePath())
 	if err != nil {
 		return nil, fmt.Errorf("unable to create checkpoint manager: %v", err)
 	}
 
-	cpLockPath := filepath.Join(config.DriverPluginPath(), "cp.lock")
+	cpLockPath := filepath.Join(config.DriverStatePath(), "cp.lock")
 
 	state := &DeviceState{
 		cdi:               cdi,
@@ -167,7 +169,20 @@ func NewDeviceState(ctx context.Context, config *Config) (*DeviceState, error) {
 		checkpointManager: checkpointManager,
 		cplock:            flock.NewFlock(cpLockPath),
 	}
-	state.checkpointCleanupManager = NewCheckpointCleanupManager(state, config.clientsets.Resource)
+	state.checkpointCleanupManager = NewCheckpointCleanupManager(state, config.clientsets.Resource, CheckpointCleanupConfig{
+		NodeName: config.flags.nodeName,
+		Mode:     config.flags.claimCleanupMode,
+		DryRun:   config.flags.claimCleanupDryRun,
+
+		Interval:             config.flags.claimCleanupInterval,
+		StartJitter:          config.flags.claimCleanupStartJitter,
+		MaxUnprepareAttempts: config.flags.claimCleanupMaxUnprepareAttempts,
+	})
+
+	if err := state.loadHealthHistory(); err != nil {
+		// The health history is informational only.
+		klog.Warningf("Starting with empty device health history: %v", err)
+	}
 
 	checkpoints, err := state.checkpointManager.ListCheckpoints()
 	if err != nil {
@@ -176,6 +191,9 @@ func NewDeviceState(ctx context.Context, config *Config) (*DeviceState, error) {
 
 	for _, c := range checkpoints {
 		if c == DriverPluginCheckpointFileBasename {
+			if cp, err := state.getCheckpoint(ctx); err == nil {
+				updatePreparedClaimsMetric(cp)
+			}
 			return state, nil
 		}
 	}
@@ -188,19 +206,24 @@ func NewDeviceState(ctx context.Context, config *Config) (*DeviceState, error) {
 }
 
 func (s *DeviceState) Prepare(ctx context.Context, claim *resourceapi.ResourceClaim) ([]kubeletplugin.Device, error) {
+	logger := klog.FromContext(ctx)
 	tplock0 := time.Now()
+	_, lspan := startSpan(ctx, "AcquireLock", attrLock.String("device-state"))
 	s.Lock()
 	defer s.Unlock()
-	klog.V(6).Infof("t_prep_state_lock_acq %.3f s", time.Since(tplock0).Seconds())
+	lspan.End()
+	observePhase(claimOpPrepare, phaseStateLock, tplock0)
+	logPhase(logger, 6, "prep_state_lock_acq", tplock0)
 
 	claimUID := string(claim.UID)
 
 	tgcp0 := time.Now()
 	cp, err := s.getCheckpoint(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("unable to get checkpoint: %v", err)
+		return nil, claimOperationError(claimOpPrepare, "checkpoint_read", fmt.Errorf("unable to get checkpoint: %v", err))
 	}
-	klog.V(7).Infof("t_prep_get_checkpoint %.3f s", time.Since(tgcp0).Seconds())
+	observePhase(claimOpPrepare, phaseCheckpointRead, tgcp0)
+	logPhase(logger, 7, "prep_get_checkpoint", tgcp0)
 
 	// Check for existing 'completed' claim preparation before updating the
 	// checkpoint with 'PrepareStarted'. Otherwise, we effectively mark a
@@ -210,12 +233,12 @@ func (s *DeviceState) Prepare(ctx context.Context, claim *resourceapi.ResourceCl
 	preparedClaim, exists := cp.V2.PreparedClaims[claimUID]
 	if exists && preparedClaim.CheckpointState == ClaimCheckpointStatePrepareCompleted {
 		if featuregates.Enabled(featuregates.HAMiCoreSupport) {
-			return nil, fmt.Errorf("claims in PrepareCompleted state are not supported when HAMiCoreSupport enabled")
+			return nil, claimOperationError(claimOpPrepare, "already_prepared", fmt.Errorf("claims in PrepareCompleted state are not supported when HAMiCoreSupport enabled"))
 		}
 		// Make this a noop. Associated device(s) has/ave been prepared by us.
 		// Prepare() must be idempotent, as it may be invoked more than once per
 		// claim (and actual device preparation must happen at most once).
-		klog.V(4).Infof("Skip prepare: claim already in PrepareCompleted state: %s", ResourceClaimToString(claim))
+		logger.V(4).Info("Skip prepare: claim already in PrepareCompleted state")
 		return preparedClaim.PreparedDevices.GetDevices(), nil
 	}
 
@@ -227,7 +250,7 @@ func (s *DeviceState) Prepare(ctx context.Context, claim *resourceapi.ResourceCl
 		// and fail the request if so (unless the prior preparation was performed with admin access).
 		// More details: https://github.com/kubernetes/kubernetes/pull/136269
 		if err := s.validateNoOverlappingPreparedDevices(cp, claim); err != nil {
-			return nil, fmt.Errorf("unable to prepare claim %v: %w", claimUID, err)
+			return nil, claimOperationError(claimOpPrepare, "overlapping_devices", fmt.Errorf("unable to prepare claim %v: %w", claimUID, err))
 		}
 
 		// Relevant for DynamicMIG: a previous preparation attempt for the same
@@ -235,9 +258,9 @@ func (s *DeviceState) Prepare(ctx context.Context, claim *resourceapi.ResourceCl
 		// that back, and retry creation from scratch (that maybe can later be
 		// optimized into filling the gaps).
 		if exists && preparedClaim.CheckpointState == ClaimCheckpointStatePrepareStarted {
-			klog.V(4).Infof("Claim %s already in PrepareStarted state: attempt rollback before new prepare", ResourceClaimToString(claim))
-			if err := s.unpreparePartiallyPrepairedClaim(claimUID, preparedClaim, cp); err != nil {
-				return nil, fmt.Errorf("unprepare failed for partially prepared claim %s failed: %w", PreparedClaimToString(&preparedClaim, claimUID), err)
+			logger.V(4).Info("Claim already in PrepareStarted state: attempt rollback before new prepare")
+			if err := s.unpreparePartiallyPrepairedClaim(ctx, claimUID, preparedClaim, cp); err != nil {
+				return nil, claimOperationError(claimOpPrepare, "rollback", fmt.Errorf("unprepare failed for partially prepared claim %s failed: %w", PreparedClaimToString(&preparedClaim, claimUID), err))
 			}
 		}
 	}
@@ -252,23 +275,25 @@ func (s *DeviceState) Prepare(ctx context.Context, claim *resourceapi.ResourceCl
 		}
 	})
 	if err != nil {
-		return nil, fmt.Errorf("unable to update checkpoint: %w", err)
+		return nil, claimOperationError(claimOpPrepare, "checkpoint_write", fmt.Errorf("unable to update checkpoint: %w", err))
 	}
-	klog.V(6).Infof("t_prep_update_checkpoint %.3f s", time.Since(tucp0).Seconds())
-	klog.V(6).Infof("checkpoint updated for claim %v", claimUID)
+	observePhase(claimOpPrepare, phaseCheckpointWrite, tucp0)
+	logPhase(logger, 6, "prep_update_checkpoint", tucp0)
+	logger.V(6).Info("Checkpoint updated", "checkpointState", ClaimCheckpointStatePrepareStarted)
 
 	tprep0 := time.Now()
 	preparedDevices, err := s.prepareDevices(ctx, claim)
-	klog.V(6).Infof("t_prep_core %.3f s (claim %s)", time.Since(tprep0).Seconds(), ResourceClaimToString(claim))
+	logPhase(logger, 6, "prep_core", tprep0)
 	if err != nil {
-		return nil, fmt.Errorf("prepare devices failed: %w", err)
+		return nil, claimOperationError(claimOpPrepare, "devices", fmt.Errorf("prepare devices failed: %w", err))
 	}
+	observePhase(claimOpPrepare, phaseDevices, tprep0)
 
 	if featuregates.Enabled(featuregates.PassthroughSupport) {
 		for _, device := range preparedDevices.GetDevices() {
 			allocatableDevice, ok := s.allocatable[device.DeviceName]
 			if !ok {
-				klog.Warningf("allocatable not found for device: %v", device.DeviceName)
+				logger.Info("Allocatable device not found", logKeyDevice, device.DeviceName)
 				continue
 			}
 			// Why do we do that -- what does that mean?
@@ -277,10 +302,14 @@ func (s *DeviceState) Prepare(ctx context.Context, claim *resourceapi.ResourceCl
 	}
 
 	tccsf0 := time.Now()
-	if err := s.cdi.CreateClaimSpecFile(claimUID, preparedDevices); err != nil {
-		return nil, fmt.Errorf("unable to create CDI spec file for claim: %w", err)
+	_, cspan := startSpan(ctx, "CreateClaimSpecFile")
+	err = s.cdi.CreateClaimSpecFile(claimUID, preparedDevices)
+	endSpan(cspan, err)
+	if err != nil {
+		return nil, claimOperationError(claimOpPrepare, "cdi_write", fmt.Errorf("unable to create CDI spec file for claim: %w", err))
 	}
-	klog.V(7).Infof("t_prep_ccsf %.3f s", time.Since(tccsf0).Seconds())
+	observePhase(claimOpPrepare, phaseCDIWrite, tccsf0)
+	logPhase(logger, 7, "prep_ccsf", tccsf0)
 
 	tucp20 := time.Now()
 	err = s.updateCheckpoint(ctx, func(cp *Checkpoint) {
@@ -288,13 +317,16 @@ func (s *DeviceState) Prepare(ctx context.Context, claim *resourceapi.ResourceCl
 			CheckpointState: ClaimCheckpointStatePrepareCompleted,
 			Status:          claim.Status,
 			PreparedDevices: preparedDevices,
+			Name:            claim.Name,
+			Namespace:       claim.Namespace,
 		}
 	})
 	if err != nil {
-		return nil, fmt.Errorf("unable to update checkpoint: %w", err)
+		return nil, claimOperationError(claimOpPrepare, "checkpoint_write", fmt.Errorf("unable to update checkpoint: %w", err))
 	}
-	klog.V(6).Infof("checkpoint updated for claim %v", claimUID)
-	klog.V(7).Infof("t_prep_ucp2 %.3f s", time.Since(tucp20).Seconds())
+	observePhase(claimOpPrepare, phaseCheckpointWrite, tucp20)
+	logger.V(6).Info("Checkpoint updated", "checkpointState", ClaimCheckpointStatePrepareCompleted)
+	logPhase(logger, 7, "prep_ucp2", tucp20)
 
 	return preparedDevices.GetDevices(), nil
 }
@@ -314,7 +346,8 @@ func (s *DeviceState) Prepare(ctx context.Context, claim *resourceapi.ResourceCl
 // device may and should affect the teardown attempt.
 //
 // There may be a fundamental need to invoke this type of cleanup periodically,
-// instead of once during startup.
+// instead of once during startup. ReconcileMIGDevices() is the (more careful)
+// periodic variant of it.
 //
 // Note: there are other cleanup strategies performed at runtime: (1) periodic
 // cleanup of partially prepared but _stale_ claims (2) attempted rollback in
@@ -350,10 +383,11 @@ func (s *DeviceState) Prepare(ctx context.Context, claim *resourceapi.ResourceCl
 // etc). There is a lot of room, especially over time, for state to be drifting
 // as of partially performed transactions.
 func (s *DeviceState) DestroyUnknownMIGDevices(ctx context.Context) {
+	logger := klog.FromContext(ctx)
 	logpfx := "Destroy unknown MIG devices"
 	cp, err := s.getCheckpoint(ctx)
 	if err != nil {
-		klog.Errorf("%s: unable to get checkpoint: %s", logpfx, err)
+		logger.Error(err, logpfx+": unable to get checkpoint")
 		return
 	}
 
@@ -376,82 +410,93 @@ func (s *DeviceState) DestroyUnknownMIGDevices(ctx context.Context) {
 		}
 	}
 
-	klog.Infof("%s: enter teardown routine (%d expect devices: %s)", logpfx, len(expectedDeviceNames), expectedDeviceNames)
+	logger.Info(logpfx+": enter teardown routine", "expectedDevices", expectedDeviceNames)
 
 	// For now, let this be best-effort. Upon error, proceed with the program,
 	// do not crash it. TODO: maybe this should be timeout-controlled.
-	if err := s.nvdevlib.obliterateStaleMIGDevices(expectedDeviceNames); err != nil {
-		klog.Errorf("%s: obliterateStaleMIGDevices failed: %s", logpfx, err)
+	if err := s.nvdevlib.obliterateStaleMIGDevices(ctx, expectedDeviceNames); err != nil {
+		logger.Error(err, logpfx+": obliterateStaleMIGDevices failed")
 	}
 
-	klog.Infof("%s: done", logpfx)
+	logger.Info(logpfx + ": done")
 }
 
 func (s *DeviceState) Unprepare(ctx context.Context, claimRef kubeletplugin.NamespacedObject) error {
+	logger := klog.FromContext(ctx)
+	tlock0 := time.Now()
+	_, lspan := startSpan(ctx, "AcquireLock", attrLock.String("device-state"))
 	s.Lock()
 	defer s.Unlock()
-	klog.V(6).Infof("Unprepare() for claim '%s'", claimRef.String())
+	lspan.End()
+	observePhase(claimOpUnprepare, phaseStateLock, tlock0)
+	logPhase(logger, 6, "unprep_state_lock_acq", tlock0)
 
+	tgcp0 := time.Now()
 	checkpoint, err := s.getCheckpoint(ctx)
 	if err != nil {
-		return fmt.Errorf("unable to get checkpoint: %v", err)
+		return claimOperationError(claimOpUnprepare, "checkpoint_read", fmt.Errorf("unable to get checkpoint: %v", err))
 	}
+	observePhase(claimOpUnprepare, phaseCheckpointRead, tgcp0)
 
 	claimUID := string(claimRef.UID)
 	pc, exists := checkpoint.V2.PreparedClaims[claimUID]
 	if !exists {
 		// Not an error: if this claim UID is not in the checkpoint then this
 		// device was never prepared or has already been unprepared (assume that
-		// Prepare+Checkpoint are done transactionally). Note that
-		// claimRef.String() contains namespace, name, UID.
-		klog.V(2).Infof("Unprepare noop: claim not found in checkpoint data: %v", claimRef.String())
+		// Prepare+Checkpoint are done transactionally).
+		logger.V(2).Info("Unprepare noop: claim not found in checkpoint data")
 		return nil
 	}
 
+	tdevs0 := time.Now()
 	switch pc.CheckpointState {
 	case ClaimCheckpointStatePrepareStarted:
-		if err := s.unpreparePartiallyPrepairedClaim(claimUID, pc, checkpoint); err != nil {
-			return fmt.Errorf("unprepare failed for partially prepared claim %s failed: %w", claimRef.String(), err)
+		if err := s.unpreparePartiallyPrepairedClaim(ctx, claimUID, pc, checkpoint); err != nil {
+			return claimOperationError(claimOpUnprepare, "rollback", fmt.Errorf("unprepare failed for partially prepared claim %s failed: %w", claimRef.String(), err))
 		}
 	case ClaimCheckpointStatePrepareCompleted:
 		if err := s.unprepareDevices(ctx, claimUID, pc.PreparedDevices); err != nil {
-			return fmt.Errorf("unprepare devices failed for claim %s: %w", claimRef.String(), err)
+			return claimOperationError(claimOpUnprepare, "devices", fmt.Errorf("unprepare devices failed for claim %s: %w", claimRef.String(), err))
 		}
 	default:
-		return fmt.Errorf("unsupported ClaimCheckpointState: %v", pc.CheckpointState)
+		return claimOperationError(claimOpUnprepare, "checkpoint_state", fmt.Errorf("unsupported ClaimCheckpointState: %v", pc.CheckpointState))
 	}
+	observePhase(claimOpUnprepare, phaseDevices, tdevs0)
 
 	if featuregates.Enabled(featuregates.PassthroughSupport) {
 		for _, device := range pc.PreparedDevices.GetDevices() {
 			allocatableDevice, ok := s.allocatable[device.DeviceName]
 			if !ok {
-				klog.Warningf("allocatable not found for device: %v", device.DeviceName)
+				logger.Info("Allocatable device not found", logKeyDevice, device.DeviceName)
 				continue
 			}
 			err := s.discoverSiblingAllocatables(allocatableDevice)
 			if err != nil {
-				return fmt.Errorf("error discovering sibling allocatables: %w", err)
+				return claimOperationError(claimOpUnprepare, "passthrough", fmt.Errorf("error discovering sibling allocatables: %w", err))
 			}
 		}
 	}
 
-	// TODOMIG: we delete per-claim CDI spec files here in the happy path. In
-	// regular operation, that means we don't leak files. However, upon program
-	// start, or periodically, clean up CDI spec directory just in case we're
-	// ever missing or failing to delete (a) file(s).
+	// We delete per-claim CDI spec files here in the happy path. In regular
+	// operation, that means we don't leak files. Files that we ever miss or
+	// fail to delete are taken care of by GarbageCollectCDISpecFiles().
+	tcdi0 := time.Now()
 	if err := s.cdi.DeleteClaimSpecFile(claimUID); err != nil {
 		// Just log an error -- if this fails, we still want to proceed
 		// attempting to remove the claim from the checkpoint.
-		klog.Errorf("unable to delete CDI spec file for claim %s: %s", claimRef.String(), err)
+		logger.Error(err, "Unable to delete CDI spec file")
 	}
+	observePhase(claimOpUnprepare, phaseCDIWrite, tcdi0)
 
 	// Mutate checkpoint reflecting that all devices for this claim have been
 	// unprepared, by virtue of removing its entry (based on claim UID) from the
 	// PreparedClaims map.
+	tucp0 := time.Now()
 	err = s.deleteClaimFromCheckpoint(ctx, claimRef)
 	if err != nil {
-		return fmt.Errorf("error deleting claim from checkpoint: %w", err)
+		return claimOperationError(claimOpUnprepare, "checkpoint_write", fmt.Errorf("error deleting claim from checkpoint: %w", err))
 	}
+	observePhase(claimOpUnprepare, phaseCheckpointWrite, tucp0)
 	return nil
 }
 
@@ -494,10 +539,11 @@ func (s *DeviceState) Unprepare(ctx context.Context, claimRef kubeletplugin.Name
 // server) or for a claim that is not stale but that _we_ are currently
 // preparing. In both cases, the `checkpoint` data is fresh enough; there is no
 // other entity that currently legitimately owns the device represented in `pc`.
-func (s *DeviceState) unpreparePartiallyPrepairedClaim(cuid string, pc PreparedClaim, checkpoint *Checkpoint) error {
+func (s *DeviceState) unpreparePartiallyPrepairedClaim(ctx context.Context, cuid string, pc PreparedClaim, checkpoint *Checkpoint) error {
+	logger := klog.FromContext(ctx)
 	// For now, there's nothing to do when DynamicMIG is not enabled.
 	if !featuregates.Enabled(featuregates.DynamicMIG) {
-		klog.Infof("unprepare noop: preparation started but not completed for claim %s (devices: %v)", PreparedClaimToString(&pc, cuid), pc.Status.Allocation.Devices.Results)
+		logger.Info("Unprepare noop: preparation started but not completed", "devices", pc.Status.Allocation.Devices.Results)
 	}
 
 	// When DynamicMIG is enabled, try to identify an orphaned MIG device
@@ -517,12 +563,12 @@ func (s *DeviceState) unpreparePartiallyPrepairedClaim(cuid string, pc PreparedC
 			// This may be a regular, full GPU -- in which case there's nothing
 			// to do. To be sure that we detect parser errors, log that error
 			// though.
-			klog.V(6).Infof("Device name %s failed NewMigSpecTupleFromCanonicalName() parsing (assume this is not a MIG device): %s", devname, err)
+			logger.V(6).Info("Device name failed NewMigSpecTupleFromCanonicalName() parsing (assume this is not a MIG device)", logKeyDevice, devname, "err", err)
 			continue
 		}
 
-		klog.V(1).Infof("Device %s is a MIG device, DynamicMIG mode: deleteMigDevIfExistsAndNotUsedByCompletedClaim()", devname)
-		if err := s.deleteMigDevIfExistsAndNotUsedByCompletedClaim(ms, devname, completedClaims); err != nil {
+		logger.V(1).Info("MIG device, DynamicMIG mode: deleteMigDevIfExistsAndNotUsedByCompletedClaim()", logKeyDevice, devname)
+		if err := s.deleteMigDevIfExistsAndNotUsedByCompletedClaim(ctx, ms, devname, completedClaims); err != nil {
 			return fmt.Errorf("deleteMigDevIfExistsAndNotUsedByCompletedClaim failed: %w", err)
 		}
 	}
@@ -530,6 +576,47 @@ func (s *DeviceState) unpreparePartiallyPrepairedClaim(cuid string, pc PreparedC
 	return nil
 }
 
+// GarbageCollectCDISpecFiles() deletes claim CDI spec files not referenced by
+// the checkpoint, and regenerates those missing for completely prepared
+// claims. Holds the DeviceState lock so that the checkpoint snapshot and the
+// CDI root directory listing are consistent with each other.
+func (s *DeviceState) GarbageCollectCDISpecFiles(ctx context.Context) error {
+	s.Lock()
+	defer s.Unlock()
+
+	cp, err := s.getCheckpoint(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to get checkpoint: %w", err)
+	}
+
+	return s.cdi.GarbageCollectClaimSpecFiles(ctx, cp.V2.PreparedClaims, s.regenerateClaimSpecFile)
+}
+
+// regenerateClaimSpecFile() re-creates the CDI spec file for a claim in
+// PrepareCompleted state from its checkpoint data. Container edits stemming
+// from device configuration are not part of the checkpoint and have to be
+// reconstructed: that is possible for MPS (derived from the control daemon
+// ID), but not for HAMi-core (edits refer to a per-preparation cache file).
+func (s *DeviceState) regenerateClaimSpecFile(claimUID string, pc PreparedClaim) error {
+	if featuregates.Enabled(featuregates.HAMiCoreSupport) {
+		return fmt.Errorf("not supported when HAMiCoreSupport is enabled")
+	}
+
+	devices := make(PreparedDevices, 0, len(pc.PreparedDevices))
+	for _, group := range pc.PreparedDevices {
+		g := *group
+		if id := g.ConfigState.MpsControlDaemonID; id != "" {
+			if s.mpsManager == nil {
+				return fmt.Errorf("claim uses MPS control daemon %s, but MPSSupport is disabled", id)
+			}
+			g.ConfigState.containerEdits = s.mpsManager.newMpsControlDaemonWithID(id, nil).GetCDIContainerEdits()
+		}
+		devices = append(devices, &g)
+	}
+
+	return s.cdi.CreateClaimSpecFile(claimUID, devices)
+}
+
 func (s *DeviceState) createCheckpoint(ctx context.Context, cp *Checkpoint) error {
 	klog.V(6).Info("acquire cplock (create cp)")
 	release, err := s.cplock.Acquire(ctx, flock.WithTimeout(10*time.Second))
@@ -540,12 +627,22 @@ func (s *DeviceState) createCheckpoint(ctx context.Context, cp *Checkpoint) erro
 	klog.V(7).Info("acquired cplock (createCheckpoint)")
 	err = s.checkpointManager.CreateCheckpoint(DriverPluginCheckpointFileBasename, cp)
 	klog.V(7).Info("create cp: done")
+	if err == nil {
+		updatePreparedClaimsMetric(cp)
+	}
 	return err
 }
 
-func (s *DeviceState) getCheckpoint(ctx context.Context) (*Checkpoint, error) {
+func (s *DeviceState) getCheckpoint(ctx context.Context) (_ *Checkpoint, err error) {
+	ctx, span := startSpan(ctx, "GetCheckpoint")
+	defer func() {
+		endSpan(span, err)
+	}()
+
 	klog.V(7).Info("acquire cplock (getCheckpoint)")
+	_, lspan := startSpan(ctx, "AcquireLock", attrLock.String("checkpoint"))
 	release, err := s.cplock.Acquire(ctx, flock.WithTimeout(10*time.Second))
+	endSpan(lspan, err)
 	if err != nil {
 		return nil, fmt.Errorf("error acquiring cplock: %w", err)
 	}
@@ -567,15 +664,23 @@ func (s *DeviceState) getCheckpoint(ctx context.Context) (*Checkpoint, error) {
 // certain that multiple read-mutate-write actions never overlap. Currently,
 // this is also ensured by the global PU lock -- but this inner lock is
 // explicit, tested, and can replace the global PU lock if desired.
-func (s *DeviceState) updateCheckpoint(ctx context.Context, mutate func(*Checkpoint)) error {
+func (s *DeviceState) updateCheckpoint(ctx context.Context, mutate func(*Checkpoint)) (err error) {
+	ctx, span := startSpan(ctx, "UpdateCheckpoint")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	logger := klog.FromContext(ctx)
 	tucp0 := time.Now()
-	klog.V(7).Info("acquire cplock (updateCheckpoint)")
+	logger.V(7).Info("acquire cplock (updateCheckpoint)")
+	_, lspan := startSpan(ctx, "AcquireLock", attrLock.String("checkpoint"))
 	release, err := s.cplock.Acquire(ctx, flock.WithTimeout(10*time.Second))
+	endSpan(lspan, err)
 	if err != nil {
 		return fmt.Errorf("error acquiring cplock: %w", err)
 	}
 	defer release()
-	klog.V(7).Info("acquired cplock (updateCheckpoint)")
+	logger.V(7).Info("acquired cplock (updateCheckpoint)")
 
 	checkpoint := &Checkpoint{}
 	if err := s.checkpointManager.GetCheckpoint(DriverPluginCheckpointFileBasename, checkpoint); err != nil {
@@ -592,7 +697,8 @@ func (s *DeviceState) updateCheckpoint(ctx context.Context, mutate func(*Checkpo
 	if err != nil {
 		return fmt.Errorf("unable to create checkpoint: %w", err)
 	}
-	klog.V(6).Infof("t_checkpoint_update_total %.3f s", time.Since(tucp0).Seconds())
+	updatePreparedClaimsMetric(cp)
+	logPhase(logger, 6, "checkpoint_update_total", tucp0)
 	return nil
 }
 
@@ -603,7 +709,7 @@ func (s *DeviceState) deleteClaimFromCheckpoint(ctx context.Context, claimRef ku
 	if err != nil {
 		return fmt.Errorf("unable to update checkpoint: %w", err)
 	}
-	klog.V(6).Infof("Deleted claim from checkpoint: %s", claimRef.String())
+	klog.FromContext(ctx).V(6).Info("Deleted claim from checkpoint")
 	return nil
 }
 
@@ -612,7 +718,8 @@ func (s *DeviceState) prepareDevices(ctx context.Context, claim *resourceapi.Res
 		return nil, fmt.Errorf("claim not yet allocated")
 	}
 
-	klog.V(6).Infof("Preparing devices for claim %s", ResourceClaimToString(claim))
+	logger := klog.FromContext(ctx)
+	logger.V(6).Info("Preparing devices")
 
 	// Retrieve the full set of device configs for the driver.
 	configs, err := GetOpaqueDeviceConfigs(
@@ -654,8 +761,10 @@ func (s *DeviceState) prepareDevices(ctx context.Context, claim *resourceapi.Res
 		if !exists {
 			return nil, fmt.Errorf("requested device is not allocatable: %v", result.Device)
 		}
-		// only proceed with config mapping if device is healthy.
-		if featuregates.Enabled(featuregates.NVMLDeviceHealthCheck) {
+		// only proceed with config mapping if device is healthy. With device
+		// taints, an unhealthy device can only have been allocated to a claim
+		// tolerating its taints: that is deliberate.
+		if featuregates.Enabled(featuregates.NVMLDeviceHealthCheck) && !featuregates.Enabled(featuregates.DeviceHealthTaints) {
 			if !device.IsHealthy() {
 				return nil, fmt.Errorf("requested device is not healthy: %v", result.Device)
 			}
@@ -781,11 +890,15 @@ func (s *DeviceState) prepareDevices(ctx context.Context, claim *resourceapi.Res
 				// persist data to disk that may be useful for cleaning up a
 				// partial prepare more reliably (such as the MIG device UUID).
 				tcmig0 := time.Now()
-				migdev, err := s.nvdevlib.createMigDevice(migspec)
-				klog.V(6).Infof("t_prep_create_mig_dev %.3f s (claim %s)", time.Since(tcmig0).Seconds(), ResourceClaimToString(claim))
+				_, nspan := startSpan(ctx, "nvml.CreateMigDevice", attrDevice.String(result.Device))
+				migdev, err := s.nvdevlib.createMigDevice(ctx, migspec)
+				endSpan(nspan, err)
+				observePhase(claimOpPrepare, phaseMIGCreation, tcmig0)
+				logPhase(logger.WithValues(logKeyDevice, result.Device), 6, "prep_create_mig_dev", tcmig0)
 				if err != nil {
 					return nil, fmt.Errorf("error creating MIG device: %w", err)
 				}
+				s.config.claimEventf(claim, corev1.EventTypeNormal, "MIGDeviceCreated", "Created MIG device %s (%s) for request %s", migdev.CanonicalName(), migdev.UUID, result.Request)
 				preparedDevice.Mig = &PreparedMigDevice{
 					Concrete: migdev.LiveTuple(),
 					Device:   device,
@@ -797,7 +910,7 @@ func (s *DeviceState) prepareDevices(ctx context.Context, claim *resourceapi.Res
 				}
 			}
 
-			klog.V(6).Infof("Prepared device for claim '%s': %s", ResourceClaimToString(claim), device.DeviceName)
+			logger.V(6).Info("Prepared device", logKeyDevice, device.DeviceName)
 			// Here is a unique opportunity to update the checkpoint, reflecting
 			// the current state of device preparation (still within
 			// PrepareStarted state, but with more detail than before). There is
@@ -813,7 +926,8 @@ func (s *DeviceState) prepareDevices(ctx context.Context, claim *resourceapi.Res
 }
 
 func (s *DeviceState) unprepareDevices(ctx context.Context, claimUID string, devices PreparedDevices) error {
-	klog.V(6).Infof("Unpreparing claim '%s', previously prepared devices from checkpoint: %v", claimUID, devices.GetDeviceNames())
+	logger := klog.FromContext(ctx)
+	logger.V(6).Info("Unpreparing devices previously prepared according to checkpoint", "devices", devices.GetDeviceNames())
 	for _, group := range devices {
 		// Unconfigure the vfio-pci devices.
 		if featuregates.Enabled(featuregates.PassthroughSupport) {
@@ -827,13 +941,13 @@ func (s *DeviceState) unprepareDevices(ctx context.Context, claimUID string, dev
 		for _, device := range group.Devices {
 			switch device.Type() {
 			case HAMiGpuDeviceType:
-				klog.V(4).Infof("Unprepare: HAMi-Core GPU: clean up temporary files for HAMi-Core (GPU %s)", device.HAMiGpu.Info.String())
+				logger.V(4).Info("Unprepare: HAMi-Core GPU: clean up temporary files for HAMi-Core", logKeyDevice, device.CanonicalName(), logKeyGPUUUID, device.HAMiGpu.Info.UUID)
 			case GpuDeviceType:
-				klog.V(4).Infof("Unprepare: regular GPU: noop (GPU %s)", device.Gpu.Info.String())
+				logger.V(4).Info("Unprepare: regular GPU: noop", logKeyDevice, device.CanonicalName(), logKeyGPUUUID, device.Gpu.Info.UUID)
 			case PreparedMigDeviceType:
 				if featuregates.Enabled(featuregates.DynamicMIG) {
 					mig := device.Mig.Concrete
-					klog.V(4).Infof("Unprepare: tear down MIG device '%s' for claim '%s'", mig.MigUUID, claimUID)
+					logger.V(4).Info("Unprepare: tear down MIG device", logKeyDevice, device.CanonicalName(), logKeyMIGUUID, mig.MigUUID)
 					// Errors during MIG device deletion are generally rare but
 					// have to be expected, and should fail the
 					// NodeUnprepareResources() operation. This may for example
@@ -841,19 +955,21 @@ func (s *DeviceState) unprepareDevices(ctx context.Context, claimUID string, dev
 					// client' and resolve itself soon; when the conflicting
 					// party goes away. Log an explicit warning, in addition to
 					// returning an error.
-					err := s.nvdevlib.deleteMigDevice(mig)
+					_, nspan := startSpan(ctx, "nvml.DeleteMigDevice", attrDevice.String(device.Mig.Device.DeviceName))
+					err := s.nvdevlib.deleteMigDevice(ctx, mig)
+					endSpan(nspan, err)
 					if err != nil {
-						klog.Warningf("Error deleting MIG device %s: %s", device.Mig.Device.DeviceName, err)
+						logger.Error(err, "Error deleting MIG device", logKeyDevice, device.Mig.Device.DeviceName)
 						return fmt.Errorf("error deleting MIG device %s: %w", device.Mig.Device.DeviceName, err)
 					}
 				} else {
-					klog.V(4).Infof("Unprepare: static MIG: noop (MIG %s)", device.Mig.Concrete.MigUUID)
+					logger.V(4).Info("Unprepare: static MIG: noop", logKeyDevice, device.CanonicalName(), logKeyMIGUUID, device.Mig.Concrete.MigUUID)
 				}
 			}
 		}
 
 		if featuregates.Enabled(featuregates.HAMiCoreSupport) {
-			err := s.hamiCoreManager.Unprepare(claimUID, group.Devices.HAMiGpus())
+			err := s.hamiCoreManager.Unprepare(ctx, claimUID, group.Devices.HAMiGpus())
 			if err != nil {
 				return fmt.Errorf("error cleanup hami devices: %w", err)
 			}
@@ -870,7 +986,7 @@ func (s *DeviceState) unprepareDevices(ctx context.Context, claimUID string, dev
 		// Go back to default time-slicing for all full GPUs.
 		if featuregates.Enabled(featuregates.TimeSlicingSettings) {
 			tsc := configapi.DefaultGpuConfig().Sharing.TimeSlicingConfig
-			if err := s.tsManager.SetTimeSlice(group.Devices.GpuUUIDs(), tsc); err != nil {
+			if err := s.tsManager.SetTimeSlice(ctx, group.Devices.GpuUUIDs(), tsc); err != nil {
 				return fmt.Errorf("error setting timeslice for devices: %w", err)
 			}
 		}
@@ -940,13 +1056,13 @@ func (s *DeviceState) discoverSiblingAllocatables(device *AllocatableDevice) err
 func (s *DeviceState) applyConfig(ctx context.Context, config configapi.Interface, claim *resourceapi.ResourceClaim, results []*resourceapi.DeviceRequestAllocationResult) (*DeviceConfigState, error) {
 	switch castConfig := config.(type) {
 	case *configapi.GpuConfig:
-		klog.V(7).Infof("applySharingConfig() for GpuConfig")
+		klog.FromContext(ctx).V(7).Info("applySharingConfig() for GpuConfig")
 		return s.applySharingConfig(ctx, castConfig.Sharing, claim, results)
 	case *configapi.MigDeviceConfig:
-		klog.V(7).Infof("applySharingConfig() for MigDeviceConfig")
+		klog.FromContext(ctx).V(7).Info("applySharingConfig() for MigDeviceConfig")
 		return s.applySharingConfig(ctx, castConfig.Sharing, claim, results)
 	case *configapi.VfioDeviceConfig:
-		klog.V(7).Infof("applySharingConfig() for VfioDeviceConfig")
+		klog.FromContext(ctx).V(7).Info("applySharingConfig() for VfioDeviceConfig")
 		return s.applyVfioDeviceConfig(ctx, castConfig, claim, results)
 	default:
 		return nil, fmt.Errorf("unknown config type: %T", castConfig)
@@ -970,7 +1086,7 @@ func (s *DeviceState) applySharingConfig(ctx context.Context, config configapi.S
 	var configState DeviceConfigState
 
 	if featuregates.Enabled(featuregates.HAMiCoreSupport) {
-		configState.containerEdits = s.hamiCoreManager.GetCDIContainerEdits(claim, requestedDevices)
+		configState.containerEdits = s.hamiCoreManager.GetCDIContainerEdits(ctx, claim, requestedDevices)
 	}
 
 	// Apply time-slicing settings (if available and feature gate enabled).
@@ -987,8 +1103,9 @@ func (s *DeviceState) applySharingConfig(ctx context.Context, config configapi.S
 			// a `MigDeviceConfig`. TODO: should we do this for passthrough
 			// devices?
 			uuids := requestedDevices.GpuUUIDs()
-			klog.V(6).Infof("SetTimeSlice() for full GPUs with UUIDs: %s", uuids)
-			err = s.tsManager.SetTimeSlice(uuids, tsc)
+			_, nspan := startSpan(ctx, "nvml.SetTimeSlice")
+			err = s.tsManager.SetTimeSlice(ctx, uuids, tsc)
+			endSpan(nspan, err)
 			if err != nil {
 				return nil, fmt.Errorf("error setting timeslice config for requests '%v' in claim '%v': %w", requests, claim.UID, err)
 			}
@@ -1014,6 +1131,7 @@ func (s *DeviceState) applySharingConfig(ctx context.Context, config configapi.S
 		if err := mpsControlDaemon.AssertReady(ctx); err != nil {
 			return nil, fmt.Errorf("MPS control daemon is not yet ready: %w", err)
 		}
+		s.config.claimEventf(claim, corev1.EventTypeNormal, "MPSControlDaemonStarted", "Started MPS control daemon %s for device(s) %v", mpsControlDaemon.GetID(), requestedDevices.UUIDs())
 		configState.MpsControlDaemonID = mpsControlDaemon.GetID()
 		configState.containerEdits = mpsControlDaemon.GetCDIContainerEdits()
 	}
@@ -1034,6 +1152,7 @@ func (s *DeviceState) applyVfioDeviceConfig(ctx context.Context, config *configa
 		if err != nil {
 			return nil, err
 		}
+		s.config.claimEventf(claim, corev1.EventTypeNormal, "VfioDeviceBound", "Bound device %s (%s) to vfio-pci", r.Device, info.Vfio.pcieBusID)
 	}
 
 	return &configState, nil
@@ -1105,29 +1224,106 @@ func GetOpaqueDeviceConfigs(
 	return resultConfigs, nil
 }
 
-// TODO: this needs a code comment.
+// UpdateDeviceHealthStatus sets the health status of an allocatable device.
+// Marking a device healthy also drops the device taints announcing its health
+// events (see TaintDevice()), and clears its degraded state.
 func (s *DeviceState) UpdateDeviceHealthStatus(d *AllocatableDevice, hs HealthStatus) {
 	s.Lock()
 	defer s.Unlock()
 
 	switch d.Type() {
+	case HAMiGpuDeviceType:
+		d.HAMiGpu.health = hs
+		if hs == Healthy {
+			d.HAMiGpu.healthTaints = nil
+			d.HAMiGpu.degraded = ""
+		}
 	case GpuDeviceType:
 		d.Gpu.health = hs
+		if hs == Healthy {
+			d.Gpu.healthTaints = nil
+			d.Gpu.degraded = ""
+		}
 	case MigDynamicDeviceType:
 		// Here we do not have access to a concrete MIG device. The
 		// 'allocatable' MIG device is an abstract representation to a specific
 		// MIG device.
-		klog.Warningf("UpdateDeviceHealthStatus() called for abstract, dynamic MIG device %s: %s", d.MigDynamic.CanonicalName(), hs)
+		klog.InfoS("UpdateDeviceHealthStatus() called for abstract, dynamic MIG device", logKeyDevice, d.CanonicalName(), "health", hs)
 	case MigStaticDeviceType:
 		// Does it make sense to update the health for a MIG device? Do we
 		// receive health events that are specific to individual MIG devices? If
 		// yes: does this allow for making conclusions about the parent device?
 		d.MigStatic.health = hs
+		if hs == Healthy {
+			d.MigStatic.healthTaints = nil
+			d.MigStatic.degraded = ""
+		}
 	default:
-		klog.V(6).Infof("Cannot update health status for unknown device type: %s", d.Type())
+		klog.V(6).InfoS("Cannot update health status for unknown device type", logKeyDevice, d.CanonicalName(), "type", d.Type())
 		return
 	}
-	klog.V(4).Infof("Updated device: %s health status to %s", d.UUID(), hs)
+	klog.V(4).InfoS("Updated device health status", append(deviceLogValues(d), "health", hs)...)
+}
+
+// TaintDevice adds a taint to the device taints announcing health events of
+// an allocatable device, replacing a taint with the same key. Return false if
+// that did not change the taints.
+func (s *DeviceState) TaintDevice(d *AllocatableDevice, taint resourceapi.DeviceTaint) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	var taints *[]resourceapi.DeviceTaint
+	switch d.Type() {
+	case HAMiGpuDeviceType:
+		taints = &d.HAMiGpu.healthTaints
+	case GpuDeviceType:
+		taints = &d.Gpu.healthTaints
+	case MigStaticDeviceType:
+		taints = &d.MigStatic.healthTaints
+	default:
+		klog.V(6).Infof("Cannot taint device of type: %s", d.Type())
+		return false
+	}
+
+	i := slices.IndexFunc(*taints, func(t resourceapi.DeviceTaint) bool { return t.Key == taint.Key })
+	if i < 0 {
+		*taints = append(*taints, taint)
+		return true
+	}
+	if (*taints)[i].Value == taint.Value && (*taints)[i].Effect == taint.Effect {
+		// Keep the time the taint was added first.
+		return false
+	}
+	(*taints)[i] = taint
+	return true
+}
+
+// SetDeviceDegraded marks an allocatable device as degraded by a health event
+// with the given reason. Return false if the device was already degraded for
+// that reason.
+func (s *DeviceState) SetDeviceDegraded(d *AllocatableDevice, reason string) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	var degraded *string
+	switch d.Type() {
+	case HAMiGpuDeviceType:
+		degraded = &d.HAMiGpu.degraded
+	case GpuDeviceType:
+		degraded = &d.Gpu.degraded
+	case MigStaticDeviceType:
+		degraded = &d.MigStatic.degraded
+	default:
+		klog.V(6).InfoS("Cannot degrade device of this type", logKeyDevice, d.CanonicalName(), "type", d.Type())
+		return false
+	}
+
+	if *degraded == reason {
+		return false
+	}
+	*degraded = reason
+	klog.V(4).InfoS("Marked device as degraded", append(deviceLogValues(d), "reason", reason)...)
+	return true
 }
 
 // requestedNonAdminDevices returns the set of device names requested by the claim,
@@ -1188,29 +1384,31 @@ func (s *DeviceState) validateNoOverlappingPreparedDevices(checkpoint *Checkpoin
 }
 
 // Make this best-effort for now (do not return an error, but log details).
-func (s *DeviceState) deleteMigDevIfExistsAndNotUsedByCompletedClaim(ms *MigSpecTuple, dname DeviceName, completelyPreparedClaims PreparedClaimsByUID) error {
+func (s *DeviceState) deleteMigDevIfExistsAndNotUsedByCompletedClaim(ctx context.Context, ms *MigSpecTuple, dname DeviceName, completelyPreparedClaims PreparedClaimsByUID) error {
+	logger := klog.FromContext(ctx).WithValues(logKeyDevice, dname)
 	for uid, claim := range completelyPreparedClaims {
 		for _, res := range claim.Status.Allocation.Devices.Results {
 			if res.Device == dname {
-				klog.V(1).Infof("Device %s is in use by completely prepared claim %s", dname, PreparedClaimToString(&claim, uid))
+				// Attributed to the claim using the device (not to the one of ctx).
+				klog.V(1).InfoS("Device is in use by completely prepared claim", logKeyDevice, dname, logKeyClaim, klog.KRef(claim.Namespace, claim.Name), logKeyClaimUID, uid)
 				return nil
 			}
 		}
 	}
 
-	klog.V(1).Infof("Device '%s' is not in use by any completely prepared claim, find corresponding actual MIG device", dname)
+	logger.V(1).Info("Device is not in use by any completely prepared claim, find corresponding actual MIG device")
 	mlt, err := s.nvdevlib.FindMigDevBySpec(ms)
 	if err != nil {
 		return fmt.Errorf("FindMigDevBySpecTuple() failed for %s: %s", dname, err)
 	}
 
 	if mlt == nil {
-		klog.V(1).Infof("No live MIG device corresponding to name %s currently exists (nothing to clean up)", dname)
+		logger.V(1).Info("No live MIG device corresponding to name currently exists (nothing to clean up)")
 		return nil
 	}
 
-	klog.V(1).Infof("MIG device corresponding to name %s found with UUID %s -- attempt to tear down", dname, mlt.MigUUID)
-	if err := s.nvdevlib.deleteMigDevice(mlt); err != nil {
+	logger.V(1).Info("Live MIG device found, attempt to tear down", logKeyMIGUUID, mlt.MigUUID)
+	if err := s.nvdevlib.deleteMigDevice(ctx, mlt); err != nil {
 		return fmt.Errorf("MIG device deletion failed: %w", err)
 	}
 
@@ -18,9 +18,11 @@ package main
 
 import (
 	"fmt"
+	"slices"
 
 	"github.com/Masterminds/semver"
 	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/Project-HAMi/k8s-dra-driver/pkg/featuregates"
 	resourceapi "k8s.io/api/resource/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/dynamic-resource-allocation/deviceattribute"
@@ -34,8 +36,25 @@ const (
 	Healthy HealthStatus = "Healthy"
 	// With NVMLDeviceHealthCheck, Unhealthy means that there are critcal xid errors on the device.
 	Unhealthy HealthStatus = "Unhealthy"
+	// Degraded is only used in the health history: a degraded device is
+	// healthy (see DeviceState.SetDeviceDegraded()).
+	Degraded HealthStatus = "Degraded"
 )
 
+// addHealthAttributes announces whether a device is degraded (usable, but
+// e.g. throttled or accumulating corrected ECC errors), and the reason of the
+// latest health event if the device is unhealthy or degraded.
+func addHealthAttributes(attrs map[resourceapi.QualifiedName]resourceapi.DeviceAttribute, health HealthStatus, degraded string, history []HealthRecord) {
+	if !featuregates.Enabled(featuregates.NVMLDeviceHealthCheck) {
+		return
+	}
+	attrs["degraded"] = resourceapi.DeviceAttribute{BoolValue: ptr.To(degraded != "")}
+	if (health == Healthy && degraded == "") || len(history) == 0 {
+		return
+	}
+	attrs["healthReason"] = resourceapi.DeviceAttribute{StringValue: ptr.To(history[len(history)-1].Reason)}
+}
+
 // Represents a specific, full, physical GPU device.
 type GpuInfo struct {
 	UUID                  string `json:"uuid"`
@@ -54,6 +73,10 @@ type GpuInfo struct {
 	migProfiles           []*MigProfileInfo
 	addressingMode        *string
 	health                HealthStatus
+	healthTaints          []resourceapi.DeviceTaint
+	// Reason of the last event degrading this GPU; empty if not degraded.
+	degraded      string
+	healthHistory []HealthRecord
 
 	// The following properties that can only be known after inspecting MIG
 	// profiles.
@@ -90,6 +113,9 @@ type MigDeviceInfo struct {
 	pcieBusID     string
 	pcieRootAttr  *deviceattribute.DeviceAttribute
 	health        HealthStatus
+	healthTaints  []resourceapi.DeviceTaint
+	degraded      string
+	healthHistory []HealthRecord
 }
 
 type VfioDeviceInfo struct {
@@ -175,6 +201,8 @@ func (d *GpuInfo) GetDevice() resourceapi.Device {
 			StringValue: d.addressingMode,
 		}
 	}
+	addHealthAttributes(device.Attributes, d.health, d.degraded, d.healthHistory)
+	device.Taints = slices.Clone(d.healthTaints)
 	return device
 }
 
@@ -263,6 +291,8 @@ func (d *MigDeviceInfo) GetDevice() resourceapi.Device {
 			StringValue: d.parent.addressingMode,
 		}
 	}
+	addHealthAttributes(device.Attributes, d.health, d.degraded, d.healthHistory)
+	device.Taints = slices.Clone(d.healthTaints)
 	return device
 }
 
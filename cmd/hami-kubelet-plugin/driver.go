@@ -26,6 +26,8 @@ import (
 	"time"
 
 	"github.com/Masterminds/semver"
+	"go.opentelemetry.io/otel/attribute"
+	corev1 "k8s.io/api/core/v1"
 	resourceapi "k8s.io/api/resource/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/runtime"
@@ -47,7 +49,8 @@ const DriverPrepUprepFlockFileName = "pu.lock"
 type deviceHealthMonitor interface {
 	Start(context.Context) error
 	Stop()
-	Unhealthy() <-chan *AllocatableDevice
+	Unhealthy() <-chan *deviceHealthEvent
+	Healthy() <-chan *AllocatableDevice
 }
 
 type driver struct {
@@ -56,8 +59,12 @@ type driver struct {
 	state               *DeviceState
 	pulock              *flock.Flock
 	healthcheck         *healthcheck
+	statusServer        *statusServer
+	debugServer         *debugServer
 	deviceHealthMonitor deviceHealthMonitor
 	wg                  sync.WaitGroup
+	// Serializes publishInventory().
+	inventoryMutex sync.Mutex
 	// Idicates whether to use separate ResourceSlices for SharedCounters and
 	// Devices (required for k8s 1.35+) or combined SharedCounters and Devices
 	// in the same slice (required for k8s 1.34).
@@ -65,6 +72,10 @@ type driver struct {
 }
 
 func NewDriver(ctx context.Context, config *Config) (*driver, error) {
+	if err := validateUnhealthyClaimAction(config.flags.unhealthyClaimAction); err != nil {
+		return nil, err
+	}
+
 	state, err := NewDeviceState(ctx, config)
 	if err != nil {
 		return nil, err
@@ -112,7 +123,7 @@ func NewDriver(ctx context.Context, config *Config) (*driver, error) {
 		}
 	}
 
-	puLockPath := filepath.Join(config.DriverPluginPath(), DriverPrepUprepFlockFileName)
+	puLockPath := filepath.Join(config.DriverStatePath(), DriverPrepUprepFlockFileName)
 
 	driver := &driver{
 		client:                 config.clientsets.Core,
@@ -136,12 +147,24 @@ func NewDriver(ctx context.Context, config *Config) (*driver, error) {
 	}
 	driver.pluginhelper = helper
 
-	healthcheck, err := startHealthcheck(ctx, config, helper)
+	healthcheck, err := startHealthcheck(ctx, config, helper, state)
 	if err != nil {
 		return nil, fmt.Errorf("start healthcheck: %w", err)
 	}
 	driver.healthcheck = healthcheck
 
+	statusServer, err := startStatusServer(config, state)
+	if err != nil {
+		return nil, fmt.Errorf("start status server: %w", err)
+	}
+	driver.statusServer = statusServer
+
+	debugServer, err := startDebugServer(config, state)
+	if err != nil {
+		return nil, fmt.Errorf("start debug server: %w", err)
+	}
+	driver.debugServer = debugServer
+
 	if featuregates.Enabled(featuregates.NVMLDeviceHealthCheck) {
 		deviceHealthMonitor, err := newNvmlDeviceHealthMonitor(config, state.allocatable, state.nvdevlib)
 		if err != nil {
@@ -155,7 +178,7 @@ func NewDriver(ctx context.Context, config *Config) (*driver, error) {
 		driver.wg.Add(1)
 		go func() {
 			defer driver.wg.Done()
-			driver.deviceHealthEvents(ctx, config.flags.nodeName)
+			driver.deviceHealthEvents(ctx)
 		}()
 	}
 
@@ -164,9 +187,32 @@ func NewDriver(ctx context.Context, config *Config) (*driver, error) {
 		return nil, fmt.Errorf("error starting CheckpointCleanupManager: %w", err)
 	}
 
+	driver.wg.Add(1)
+	go func() {
+		defer driver.wg.Done()
+		driver.cdiSpecGarbageCollection(ctx)
+	}()
+
+	if featuregates.Enabled(featuregates.DynamicMIG) && config.flags.migReconcileInterval > 0 {
+		driver.wg.Add(1)
+		go func() {
+			defer driver.wg.Done()
+			driver.migReconcileLoop(ctx, config.flags.migReconcileInterval, config.flags.migReconcileTeardown)
+		}()
+	}
+
+	if config.flags.metricsPort >= 0 && config.flags.gpuTelemetryInterval > 0 {
+		driver.wg.Add(1)
+		go func() {
+			defer driver.wg.Done()
+			driver.gpuTelemetryLoop(ctx, config.flags.gpuTelemetryInterval)
+		}()
+	}
+
 	if err := driver.publishResources(ctx, config); err != nil {
 		return nil, err
 	}
+	driver.publishInventory(ctx)
 
 	klog.V(4).Infof("Current kubelet plugin registration status: %s", helper.RegistrationStatus())
 
@@ -198,13 +244,17 @@ func (d *driver) generateSplitResourceSlices(nodeName string) resourceslice.Driv
 		// Stable sort order by devicename
 		for _, devname := range slices.Sorted(maps.Keys(allocatable)) {
 			device := allocatable[devname]
-			klog.V(4).Infof("About to announce device %s", devname)
+			klog.V(4).InfoS("About to announce device", logKeyDevice, devname)
 
 			// Full GPU: collect its counter sets for the `sharedCountersSlice`.
 			if device.Gpu != nil {
 				allCounterSets = append(allCounterSets, device.Gpu.PartSharedCounterSets()...)
 			}
 
+			if !announceDevice(device) {
+				continue
+			}
+
 			// Add device/partition to the device-only slice for this GPU.
 			deviceSlice.Devices = append(deviceSlice.Devices, device.PartGetDevice())
 		}
@@ -244,7 +294,7 @@ func (d *driver) generateCombinedResourceSlices(nodeName string) resourceslice.D
 		// restart (the slice diff is logged).
 		for _, devname := range slices.Sorted(maps.Keys(allocatable)) {
 			device := allocatable[devname]
-			klog.V(4).Infof("About to announce device %s", devname)
+			klog.V(4).InfoS("About to announce device", logKeyDevice, devname)
 
 			// Full GPU: take note of countersets, indicating absolute capacity.
 			// For now this is expected to be one counter set.
@@ -252,6 +302,10 @@ func (d *driver) generateCombinedResourceSlices(nodeName string) resourceslice.D
 				countersets = append(countersets, device.Gpu.PartSharedCounterSets()...)
 			}
 
+			if !announceDevice(device) {
+				continue
+			}
+
 			// Add all allocatable devices for this physical GPU to this slice.
 			// This includes not-yet-manifested MIG devices, and the physical
 			// GPU itself.
@@ -276,6 +330,8 @@ func (d *driver) Shutdown() error {
 	if d.healthcheck != nil {
 		d.healthcheck.Stop()
 	}
+	d.statusServer.Stop()
+	d.debugServer.Stop()
 
 	// Shut down long-lived NVML session.
 	if featuregates.Enabled(featuregates.DynamicMIG) {
@@ -297,16 +353,14 @@ func (d *driver) Shutdown() error {
 }
 
 func (d *driver) PrepareResourceClaims(ctx context.Context, claims []*resourceapi.ResourceClaim) (map[types.UID]kubeletplugin.PrepareResult, error) {
-
 	if len(claims) == 0 {
 		// That's probably the health check, log that on higher verbosity level
-		klog.V(7).Infof("PrepareResourceClaims called with %d claim(s)", len(claims))
-	} else {
-		// Log canonical string representation for each claim injected here --
-		// we've noticed that this can greatly facilitate debugging.
-		klog.V(6).Infof("Prepare called for: %v", ClaimsToStrings(claims))
+		klog.FromContext(ctx).V(7).Info("PrepareResourceClaims called without claims")
 	}
 
+	ctx, span := startSpan(ctx, "PrepareResourceClaims", attribute.Int("claims", len(claims)))
+	defer span.End()
+
 	results := make(map[types.UID]kubeletplugin.PrepareResult)
 
 	for _, claim := range claims {
@@ -317,7 +371,9 @@ func (d *driver) PrepareResourceClaims(ctx context.Context, claims []*resourceap
 }
 
 func (d *driver) UnprepareResourceClaims(ctx context.Context, claimRefs []kubeletplugin.NamespacedObject) (map[types.UID]error, error) {
-	klog.V(6).Infof("Unprepare called for: %v", ClaimRefsToStrings(claimRefs))
+	ctx, span := startSpan(ctx, "UnprepareResourceClaims", attribute.Int("claims", len(claimRefs)))
+	defer span.End()
+
 	results := make(map[types.UID]error)
 	for _, claimRef := range claimRefs {
 		results[claimRef.UID] = d.nodeUnprepareResource(ctx, claimRef)
@@ -332,28 +388,43 @@ func (d *driver) HandleError(ctx context.Context, err error, msg string) {
 	runtime.HandleErrorWithContext(ctx, err, msg)
 }
 
-func (d *driver) nodePrepareResource(ctx context.Context, claim *resourceapi.ResourceClaim) kubeletplugin.PrepareResult {
+func (d *driver) nodePrepareResource(ctx context.Context, claim *resourceapi.ResourceClaim) (result kubeletplugin.PrepareResult) {
+	ctx = withClaimLogger(ctx, claim.Namespace, claim.Name, claim.UID)
+	logger := klog.FromContext(ctx)
+	logger.V(6).Info("Prepare called")
+	ctx, span := startSpan(ctx, "PrepareClaim", attrClaimNamespace.String(claim.Namespace), attrClaimName.String(claim.Name))
+	defer func() {
+		endSpan(span, result.Err)
+	}()
+
 	// Instead of a global prepare/unprepare (PU) lock, we could rely on
 	// fine-grained checkpoint locking, which was proven to work correctly in
 	// case of DynamicMIG mode. However, out of caution, retain this global PU
 	// lock for now in all modes (re-evaluate the performance impact at a later
 	// time).
 	t0 := time.Now()
+	defer observePhase(claimOpPrepare, phaseTotal, t0)
+	_, lspan := startSpan(ctx, "AcquireLock", attrLock.String("prep-unprep"))
 	release, err := d.pulock.Acquire(ctx, flock.WithTimeout(10*time.Second))
+	endSpan(lspan, err)
 	if err != nil {
 		return kubeletplugin.PrepareResult{
-			Err: fmt.Errorf("error acquiring prep/unprep lock: %w", err),
+			Err: claimOperationError(claimOpPrepare, "lock", fmt.Errorf("error acquiring prep/unprep lock: %w", err)),
 		}
 	}
 	defer release()
-	klog.V(6).Infof("t_prep_lock_acq %.3f s", time.Since(t0).Seconds())
+	observePhase(claimOpPrepare, phaseLockAcquisition, t0)
+	logPhase(logger, 6, "prep_lock_acq", t0)
 
+	config := d.state.config
 	cs := ResourceClaimToString(claim)
+	config.claimEventf(claim, corev1.EventTypeNormal, "PrepareStarted", "Preparing devices on node %s", config.flags.nodeName)
 	tprep0 := time.Now()
 	devs, err := d.state.Prepare(ctx, claim)
-	klog.V(6).Infof("t_prep %.3f s (claim %s)", time.Since(tprep0).Seconds(), cs)
+	logPhase(logger, 6, "prep", tprep0)
 
 	if err != nil {
+		config.claimEventf(claim, corev1.EventTypeWarning, "PrepareFailed", "Failed to prepare devices on node %s: %v", config.flags.nodeName, err)
 		return kubeletplugin.PrepareResult{
 			Err: fmt.Errorf("error preparing devices for claim %s: %w", cs, err),
 		}
@@ -362,29 +433,42 @@ func (d *driver) nodePrepareResource(ctx context.Context, claim *resourceapi.Res
 	if featuregates.Enabled(featuregates.PassthroughSupport) {
 		// Re-advertise updated resourceslice after preparing devices.
 		if err = d.publishResources(ctx, d.state.config); err != nil {
+			config.claimEventf(claim, corev1.EventTypeWarning, "PrepareFailed", "Failed to publish resources on node %s: %v", config.flags.nodeName, err)
 			return kubeletplugin.PrepareResult{
-				Err: fmt.Errorf("error preparing devices for claim %v: %w", claim.UID, err),
+				Err: claimOperationError(claimOpPrepare, "publish", fmt.Errorf("error preparing devices for claim %v: %w", claim.UID, err)),
 			}
 		}
 	}
 
-	klog.Infof("Returning newly prepared devices for claim '%s': %v", cs, devs)
+	config.claimEventf(claim, corev1.EventTypeNormal, "Prepared", "Prepared %d device(s) on node %s", len(devs), config.flags.nodeName)
+	logger.Info("Returning newly prepared devices", "devices", devs)
 	return kubeletplugin.PrepareResult{Devices: devs}
 }
 
-func (d *driver) nodeUnprepareResource(ctx context.Context, claimRef kubeletplugin.NamespacedObject) error {
+func (d *driver) nodeUnprepareResource(ctx context.Context, claimRef kubeletplugin.NamespacedObject) (err error) {
+	ctx = withClaimLogger(ctx, claimRef.Namespace, claimRef.Name, claimRef.UID)
+	logger := klog.FromContext(ctx)
+	logger.V(6).Info("Unprepare called")
+	ctx, span := startSpan(ctx, "UnprepareClaim", attrClaimNamespace.String(claimRef.Namespace), attrClaimName.String(claimRef.Name))
+	defer func() {
+		endSpan(span, err)
+	}()
+
 	t0 := time.Now()
+	defer observePhase(claimOpUnprepare, phaseTotal, t0)
+	_, lspan := startSpan(ctx, "AcquireLock", attrLock.String("prep-unprep"))
 	release, err := d.pulock.Acquire(ctx, flock.WithTimeout(10*time.Second))
+	endSpan(lspan, err)
 	if err != nil {
-		return fmt.Errorf("error acquiring prep/unprep lock: %w", err)
+		return claimOperationError(claimOpUnprepare, "lock", fmt.Errorf("error acquiring prep/unprep lock: %w", err))
 	}
 	defer release()
-	klog.V(6).Infof("t_unprep_lock_acq %.3f s", time.Since(t0).Seconds())
+	observePhase(claimOpUnprepare, phaseLockAcquisition, t0)
+	logPhase(logger, 6, "unprep_lock_acq", t0)
 
-	cs := claimRef.String()
 	tunprep0 := time.Now()
 	err = d.state.Unprepare(ctx, claimRef)
-	klog.V(6).Infof("t_unprep %.3f s (claim %s)", time.Since(tunprep0).Seconds(), cs)
+	logPhase(logger, 6, "unprep", tunprep0)
 
 	if err != nil {
 		return fmt.Errorf("error unpreparing devices for claim %v: %w", claimRef.String(), err)
@@ -393,14 +477,33 @@ func (d *driver) nodeUnprepareResource(ctx context.Context, claimRef kubeletplug
 	if featuregates.Enabled(featuregates.PassthroughSupport) {
 		// Re-advertise updated resourceslice after unpreparing devices.
 		if err = d.publishResources(ctx, d.state.config); err != nil {
-			return fmt.Errorf("error publishing resources: %w", err)
+			return claimOperationError(claimOpUnprepare, "publish", fmt.Errorf("error publishing resources: %w", err))
 		}
 	}
 
 	return nil
 }
 
+// publishResources is the one code path for (re)publishing the ResourceSlices
+// of this node, reflecting the current device health (see announceDevice()).
 func (d *driver) publishResources(ctx context.Context, config *Config) error {
+	resources := d.generateResources(config.flags.nodeName)
+	if config.flags.dryRun && !config.flags.dryRunPublishResourceSlices {
+		logDryRunResources(resources)
+		return nil
+	}
+	ctx, span := startSpan(ctx, "PublishResources")
+	err := d.pluginhelper.PublishResources(ctx, resources)
+	endSpan(span, err)
+	return err
+}
+
+func (d *driver) generateResources(nodeName string) resourceslice.DriverResources {
+	// Device health is updated concurrently.
+	d.state.Lock()
+	defer d.state.Unlock()
+
+	updateAllocatableDevicesMetric(d.state.allocatable)
 
 	if featuregates.Enabled(featuregates.DynamicMIG) {
 		// From KEP 4815: "we will add client-side validation in the
@@ -411,100 +514,148 @@ func (d *driver) publishResources(ctx context.Context, config *Config) error {
 		// TODO: implement error handler for bad slices:
 		// https://github.com/kubernetes/kubernetes/commit/a171795e313ee9f407fef4897c1a1e2052120991
 		klog.V(1).Infof("featuregates.DynamicMIG enabled: construct ResourceSlice objects according to KEP 4815 (partitionable devices)")
-		resources := d.GenerateDriverResources(config.flags.nodeName)
-		if err := d.pluginhelper.PublishResources(ctx, resources); err != nil {
-			return err
-		}
-		return nil
+		return d.GenerateDriverResources(nodeName)
 	}
 
 	// Enumerate the set of GPU, MIG and VFIO devices and publish them
 	var resourceSlice resourceslice.Slice
 	for _, device := range d.state.allocatable {
-		klog.V(4).Infof("About to announce device %s", device.GetDevice().Name)
+		if !announceDevice(device) {
+			continue
+		}
+		klog.V(4).InfoS("About to announce device", logKeyDevice, device.CanonicalName())
 		resourceSlice.Devices = append(resourceSlice.Devices, device.GetDevice())
 	}
 
-	resources := resourceslice.DriverResources{
+	return resourceslice.DriverResources{
 		Pools: map[string]resourceslice.Pool{
-			config.flags.nodeName: {Slices: []resourceslice.Slice{resourceSlice}},
+			nodeName: {Slices: []resourceslice.Slice{resourceSlice}},
 		},
 	}
+}
 
-	if err := d.pluginhelper.PublishResources(ctx, resources); err != nil {
-		return err
+// announceDevice returns false for an unhealthy device that must not be
+// announced. With the DeviceHealthTaints feature gate, unhealthy devices stay
+// in the resource slice, carrying taints that keep (NoSchedule) or evict
+// (NoExecute) workloads from them.
+func announceDevice(device *AllocatableDevice) bool {
+	if device.IsHealthy() || featuregates.Enabled(featuregates.DeviceHealthTaints) {
+		return true
 	}
-
-	return nil
-
+	klog.InfoS("Unhealthy device will be removed from ResourceSlice", deviceLogValues(device)...)
+	return false
 }
 
-func (d *driver) deviceHealthEvents(ctx context.Context, nodeName string) {
-	klog.V(4).Info("Starting to watch for device health notifications")
+func (d *driver) deviceHealthEvents(ctx context.Context) {
+	logger := klog.FromContext(ctx)
+	logger.V(4).Info("Starting to watch for device health notifications")
 	for {
 		select {
 		case <-ctx.Done():
-			klog.V(6).Info("Stop processing device health notifications")
+			logger.V(6).Info("Stop processing device health notifications")
 			return
-		case device, ok := <-d.deviceHealthMonitor.Unhealthy():
+		case event, ok := <-d.deviceHealthMonitor.Unhealthy():
 			if !ok {
 				// NVML based deviceHealthMonitor is expected to close only during driver Shutdown.
-				klog.V(6).Info("Health monitor channel closed")
+				logger.V(6).Info("Health monitor channel closed")
 				return
 			}
-			uuid := device.UUID()
+			device := event.device
+			dlogger := logger.WithValues(deviceLogValues(device)...)
+
+			if event.rule.Action == HealthActionDegrade {
+				dlogger.Info("Received degraded notification for device", "reason", event.reason)
+				if !d.state.SetDeviceDegraded(device, event.reason) {
+					dlogger.V(6).Info("Device is already marked degraded. Skip republishing ResourceSlice")
+					continue
+				}
+				d.recordHealthTransition(dlogger, device, event.healthRecord(Degraded))
+				d.publishAfterHealthUpdate(ctx)
+				continue
+			}
 
-			klog.Warningf("Received unhealthy notification for device: %s", uuid)
+			dlogger.Info("Received unhealthy notification for device", "reason", event.reason)
 
-			if !device.IsHealthy() {
-				klog.V(6).Infof("Device: %s is aleady marked unhealthy. Skip republishing ResourceSlice", uuid)
+			// With device taints, every new kind of health event for a device
+			// is announced (a taint per XID), not only the first one.
+			tainted := featuregates.Enabled(featuregates.DeviceHealthTaints) && d.state.TaintDevice(device, event.taint())
+
+			if !device.IsHealthy() && !tainted {
+				dlogger.V(6).Info("Device is already marked unhealthy. Skip republishing ResourceSlice")
 				continue
 			}
 
 			// Mark device as unhealthy.
 			d.state.UpdateDeviceHealthStatus(device, Unhealthy)
-
-			// Republish resource slice with only healthy devices
-			// There is no remediation loop right now meaning if the unhealthy device is fixed,
-			// driver needs to be restarted to publish the ResourceSlice with all devices
-			var resourceSlice resourceslice.Slice
-			for _, dev := range d.state.allocatable {
-				uuid := dev.UUID()
-				if dev.IsHealthy() {
-					klog.V(6).Infof("Device: %s is healthy, added to ResoureSlice", uuid)
-					resourceSlice.Devices = append(resourceSlice.Devices, dev.GetDevice())
-				} else {
-					klog.Warningf("Device: %s is unhealthy, will be removed from ResoureSlice", uuid)
-				}
+			d.recordHealthTransition(dlogger, device, event.healthRecord(Unhealthy))
+			d.publishAfterHealthUpdate(ctx)
+			// Flagging claims takes API round trips per claim: do not hold
+			// up the handling of further events.
+			d.wg.Add(1)
+			go func() {
+				defer d.wg.Done()
+				d.failClaimsOnDevice(ctx, event)
+			}()
+		case device, ok := <-d.deviceHealthMonitor.Healthy():
+			if !ok {
+				logger.V(6).Info("Health monitor channel closed")
+				return
 			}
+			dlogger := logger.WithValues(deviceLogValues(device)...)
 
-			klog.V(4).Info("Rebulishing resourceslice with healthy devices")
-			resources := resourceslice.DriverResources{
-				Pools: map[string]resourceslice.Pool{
-					nodeName: {Slices: []resourceslice.Slice{resourceSlice}},
-				},
-			}
+			dlogger.Info("Received healthy notification for device")
 
-			// NOTE: We only log an error on publish failure and do not retry.
-			// If this publish fails, our in-memory health update succeeds but the
-			// ResourceSlice in the API server remains stale and still advertises the
-			// now-unhealthy device as allocatable. Until a later publish succeeds,
-			// the scheduler and other consumers will continue to see the unhealthy
-			// device as available, and new pods may be placed onto hardware we know
-			// is unusable. If publishes continue to fail (e.g., API server issues),
-			// the cluster can remain in this inconsistent state indefinitely.
-			// This is a temporary compromise while device taints/tolerations (KEP-5055)
-			// are available as a Beta feature. An interim improvement could be adding
-			// a retry/backoff or switch to patch updates instead of full republish.
-			if err := d.pluginhelper.PublishResources(ctx, resources); err != nil {
-				klog.Errorf("Failed to publish resources after device health status update: %v", err)
-			} else {
-				klog.V(4).Info("Successfully republished resources without unhealthy device")
+			if device.IsHealthy() && !device.IsDegraded() {
+				dlogger.V(6).Info("Device is already marked healthy. Skip republishing ResourceSlice")
+				continue
 			}
+
+			d.state.UpdateDeviceHealthStatus(device, Healthy)
+			d.recordHealthTransition(dlogger, device, HealthRecord{Time: time.Now(), Health: Healthy, Reason: "recovered"})
+			d.publishAfterHealthUpdate(ctx)
 		}
 	}
 }
 
+// recordHealthTransition adds the record to the health history of the device,
+// and announces it with an Event on the Node.
+func (d *driver) recordHealthTransition(logger klog.Logger, device *AllocatableDevice, record HealthRecord) {
+	if err := d.state.AddHealthRecord(device, record); err != nil {
+		logger.Error(err, "Failed to record health transition of device")
+	}
+
+	eventType := corev1.EventTypeWarning
+	if record.Health == Healthy {
+		eventType = corev1.EventTypeNormal
+	}
+	config := d.state.config
+	config.eventRecorder.Eventf(config.NodeRef(), eventType, "Device"+string(record.Health), "Device %s (%s) is %s", device.CanonicalName(), device.UUID(), record)
+}
+
+// publishAfterHealthUpdate republishes the resource slices after a change
+// of device health.
+func (d *driver) publishAfterHealthUpdate(ctx context.Context) {
+	klog.V(4).Info("Republishing resourceslices after device health status update")
+
+	// NOTE: We only log an error on publish failure and do not retry.
+	// If this publish fails, our in-memory health update succeeds but the
+	// ResourceSlice in the API server remains stale and still advertises the
+	// now-unhealthy device as allocatable. Until a later publish succeeds,
+	// the scheduler and other consumers will continue to see the unhealthy
+	// device as available, and new pods may be placed onto hardware we know
+	// is unusable. If publishes continue to fail (e.g., API server issues),
+	// the cluster can remain in this inconsistent state indefinitely.
+	// Device taints (KEP-5055) close that gap only partially: a stale
+	// ResourceSlice lacks the taint. An interim improvement could be adding
+	// a retry/backoff or switch to patch updates instead of full republish.
+	if err := d.publishResources(ctx, d.state.config); err != nil {
+		klog.Errorf("Failed to publish resources after device health status update: %v", err)
+	} else {
+		klog.V(4).Info("Successfully republished resources after device health status update")
+	}
+	d.publishInventory(ctx)
+}
+
 // shouldUseSplitResourceSlices detects the Kubernetes server version and
 // returns true if separate ResourceSlices should be used for SharedCounters and
 // Devices (required for k8s 1.35+), or false if they must be combined (k8s
@@ -540,13 +691,41 @@ func getAPIServerVersion(client coreclientset.Interface) (*semver.Version, error
 	return semver, nil
 }
 
-// TODO: implement loop to remove CDI files from the CDI path for claimUIDs
-//       that have been removed from the AllocatedClaims map.
-// func (d *driver) cleanupCDIFiles(wg *sync.WaitGroup) chan error {
-// 	errors := make(chan error)
-// 	return errors
-// }
-//
+// cdiSpecGarbageCollection() reconciles claim CDI spec files with the
+// checkpoint once upon startup and then periodically, until the context is
+// canceled.
+func (d *driver) cdiSpecGarbageCollection(ctx context.Context) {
+	ticker := time.NewTicker(CDISpecGarbageCollectionInterval)
+	defer ticker.Stop()
+	defer backgroundLoops.stop(loopCDISpecGC)
+
+	for {
+		backgroundLoops.beat(loopCDISpecGC, 2*CDISpecGarbageCollectionInterval)
+		d.garbageCollectCDISpecFiles(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *driver) garbageCollectCDISpecFiles(ctx context.Context) {
+	// Take the prep/unprep lock: a Prepare() in another plugin process (e.g.
+	// during an upgrade) must not write a spec file while we decide which spec
+	// files are orphaned.
+	release, err := d.pulock.Acquire(ctx, flock.WithTimeout(10*time.Second))
+	if err != nil {
+		klog.Warningf("CDI spec GC: error acquiring prep/unprep lock (retry later): %s", err)
+		return
+	}
+	defer release()
+
+	if err := d.state.GarbageCollectCDISpecFiles(ctx); err != nil {
+		klog.Warningf("CDI spec GC: %s", err)
+	}
+}
+
 // TODO: implement loop to remove mpsControlDaemon folders from the mps
 //       path for claimUIDs that have been removed from the AllocatedClaims map.
 // func (d *driver) cleanupMpsControlDaemonArtifacts(wg *sync.WaitGroup) chan error {
@@ -0,0 +1,103 @@
+/*
+Copyright 2025 The HAMi Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"k8s.io/dynamic-resource-allocation/resourceslice"
+	"k8s.io/klog/v2"
+)
+
+// In dry-run mode (see the dry-run flag), the plugin discovers devices and
+// serves kubelet as usual, but never mutates devices: the mutations that
+// would be done (the same as recorded in the audit log) are logged instead.
+// CDI specs, the checkpoint and the locks are kept in a scratch directory, so
+// that the state of the plugin that normally runs on the node is left alone.
+// ResourceSlices are only logged, unless their publication is requested. Other
+// API writes (node condition, flagging claims using unhealthy devices, GPU
+// inventory ConfigMap, MPS control daemon Deployments) are logged instead of
+// done as well; Kubernetes events are still recorded.
+
+// Prefix of the UUID of MIG devices "created" in dry-run mode.
+const dryRunMigUUIDPrefix = "MIG-dry-run-"
+
+// setupDryRun redirects CDI specs to the scratch directory. Call it before
+// the device state is created.
+func (c *Config) setupDryRun() error {
+	c.flags.cdiRoot = filepath.Join(c.flags.dryRunDir, "cdi")
+	if err := os.MkdirAll(c.flags.cdiRoot, 0750); err != nil {
+		return fmt.Errorf("error creating dry-run directory: %w", err)
+	}
+	klog.Warningf("Dry-run mode: devices are not mutated, CDI specs and checkpoint are kept in %s", c.flags.dryRunDir)
+	return nil
+}
+
+// DriverStatePath returns the directory holding the checkpoint and the
+// prepare/unprepare locks.
+func (c Config) DriverStatePath() string {
+	if c.flags.dryRun {
+		return c.flags.dryRunDir
+	}
+	return c.DriverPluginPath()
+}
+
+// dryRunMutation returns whether mutating devices is disabled; if so, it logs
+// the mutation (an audited operation) that would be done.
+func (l deviceLib) dryRunMutation(ctx context.Context, operation, target, before, after string) bool {
+	if !l.dryRun {
+		return false
+	}
+	klog.FromContext(ctx).Info("Dry-run: skipping device mutation", "operation", operation, "target", target, "before", before, "after", after)
+	return true
+}
+
+// dryRunMigDeviceInfo returns a placeholder for the MIG device that would be
+// created for migspec. It has no GPU or compute instance.
+func dryRunMigDeviceInfo(migspec *MigSpec) *MigDeviceInfo {
+	return &MigDeviceInfo{
+		UUID:           dryRunMigUUIDPrefix + string(migspec.CanonicalName()),
+		CIID:           -1,
+		GIID:           -1,
+		ParentMinor:    migspec.Parent.minor,
+		ParentUUID:     migspec.Parent.UUID,
+		Profile:        migspec.Profile.String(),
+		PlacementStart: int(migspec.Placement.Start),
+		PlacementSize:  int(migspec.Placement.Size),
+		GiProfileID:    int(migspec.GIProfileInfo.Id),
+		parent:         migspec.Parent,
+	}
+}
+
+func isDryRunMigDevice(mlt *MigLiveTuple) bool {
+	return strings.HasPrefix(mlt.MigUUID, dryRunMigUUIDPrefix)
+}
+
+// logDryRunResources logs the ResourceSlices that are not published.
+func logDryRunResources(resources resourceslice.DriverResources) {
+	data, err := json.MarshalIndent(resources, "", "  ")
+	if err != nil {
+		klog.Errorf("Dry-run: unable to marshal ResourceSlices: %v", err)
+		return
+	}
+	klog.Infof("Dry-run: not publishing ResourceSlices:\n%s", data)
+}
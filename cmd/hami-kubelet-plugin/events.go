@@ -0,0 +1,88 @@
+/*
+Copyright 2025 The HAMi Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	resourceapi "k8s.io/api/resource/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+const eventSourceComponent = "hami-kubelet-plugin"
+
+// newEventRecorder() returns a recorder emitting Kubernetes Events on behalf
+// of this plugin. The caller must shut down the returned broadcaster.
+func newEventRecorder(ctx context.Context, config *Config) (record.EventBroadcaster, record.EventRecorder) {
+	broadcaster := record.NewBroadcaster(record.WithContext(ctx))
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{
+		Interface: config.clientsets.Core.CoreV1().Events(""),
+	})
+	recorder := broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{
+		Component: eventSourceComponent,
+		Host:      config.flags.nodeName,
+	})
+	return broadcaster, recorder
+}
+
+// NodeRef() returns a reference to this plugin's Node object, for use as
+// Event subject. Like the kubelet, use the node name as UID: that makes Events
+// show up in `kubectl describe node`.
+func (c Config) NodeRef() *corev1.ObjectReference {
+	return &corev1.ObjectReference{
+		Kind: "Node",
+		Name: c.flags.nodeName,
+		UID:  types.UID(c.flags.nodeName),
+	}
+}
+
+// claimEventRefs() returns references to a ResourceClaim and to the pods it
+// is reserved for, for use as Event subjects: Events about a claim show up in
+// `kubectl describe pod` of its consumers, too.
+func claimEventRefs(namespace, name string, uid types.UID, reservedFor []resourceapi.ResourceClaimConsumerReference) []*corev1.ObjectReference {
+	refs := []*corev1.ObjectReference{{
+		APIVersion: resourceapi.SchemeGroupVersion.String(),
+		Kind:       "ResourceClaim",
+		Namespace:  namespace,
+		Name:       name,
+		UID:        uid,
+	}}
+	for _, consumer := range reservedFor {
+		if consumer.APIGroup != "" || consumer.Resource != "pods" {
+			continue
+		}
+		refs = append(refs, &corev1.ObjectReference{
+			APIVersion: "v1",
+			Kind:       "Pod",
+			Namespace:  namespace,
+			Name:       consumer.Name,
+			UID:        consumer.UID,
+		})
+	}
+	return refs
+}
+
+// claimEventf() emits an Event on the claim and on its consumer pods.
+func (c Config) claimEventf(claim *resourceapi.ResourceClaim, eventtype, reason, messageFmt string, args ...any) {
+	for _, ref := range claimEventRefs(claim.Namespace, claim.Name, claim.UID, claim.Status.ReservedFor) {
+		c.eventRecorder.Eventf(ref, eventtype, reason, messageFmt, args...)
+	}
+}
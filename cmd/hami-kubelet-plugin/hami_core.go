@@ -17,11 +17,14 @@ limitations under the License.
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"maps"
 	"os"
 	"slices"
 	"strconv"
+	"time"
 
 	"github.com/Masterminds/semver"
 	"github.com/google/uuid"
@@ -109,7 +112,9 @@ func (d *HAMiGpuInfo) GetDevice() resourceapi.Device {
 			},
 		},
 		AllowMultipleAllocations: &allowed,
+		Taints:                   slices.Clone(d.healthTaints),
 	}
+	addHealthAttributes(device.Attributes, d.health, d.degraded, d.healthHistory)
 	return device
 }
 
@@ -193,22 +198,10 @@ func (m *HAMiCoreManager) getConsumableCapacityMap(claim *resourceapi.ResourceCl
 	return resMap
 }
 
-func (m *HAMiCoreManager) GetCDIContainerEdits(claim *resourceapi.ResourceClaim, devs AllocatableDevices) *cdiapi.ContainerEdits {
+func (m *HAMiCoreManager) GetCDIContainerEdits(ctx context.Context, claim *resourceapi.ResourceClaim, devs AllocatableDevices) *cdiapi.ContainerEdits {
 	cacheFileHostDirectory := fmt.Sprintf("%s/vgpu/claims/%s", m.hostHookPath, claim.UID)
 	// TODO: We should check the status of claim, becasue there may be two pod share the claim
-	var err error
-	err = os.RemoveAll(cacheFileHostDirectory)
-	if err != nil {
-		klog.Warningf("Failed to remove host directory for cachefile %s: %s", cacheFileHostDirectory, err)
-	}
-	err = os.MkdirAll(cacheFileHostDirectory, 0777)
-	if err != nil {
-		klog.Warningf("Failed to create host directory for cachefile %s: %s", cacheFileHostDirectory, err)
-	}
-	err = os.Chmod(cacheFileHostDirectory, 0777)
-	if err != nil {
-		klog.Warningf("Failed to change mod of host directory for cachefile %s: %s", cacheFileHostDirectory, err)
-	}
+	m.createCacheFileHostDirectory(ctx, cacheFileHostDirectory)
 
 	hamiEnvs := []string{}
 	// TOOD: Get SM Limit from Claim's Annotation
@@ -218,7 +211,7 @@ func (m *HAMiCoreManager) GetCDIContainerEdits(claim *resourceapi.ResourceClaim,
 	idx := 0
 	for name, dev := range devs {
 		// TODO: The idx here may not equals to the index in nvidia-smi, So we need to find a solution to solve it
-		klog.Warningf("HAMiCoreManager GetCDIContainerEdits for dev: %s\n", name)
+		klog.FromContext(ctx).V(4).Info("HAMiCoreManager: getting CDI container edits", logKeyDevice, name)
 		capNameSMLimit := resourceapi.QualifiedName("cores")
 		capNameMemoryLimit := resourceapi.QualifiedName("memory")
 		SMLimitEnv := fmt.Sprintf("CUDA_DEVICE_SM_LIMIT_%d=%s", idx, "60")
@@ -275,9 +268,44 @@ func (m *HAMiCoreManager) GetCDIContainerEdits(claim *resourceapi.ResourceClaim,
 	}
 }
 
-func (m *HAMiCoreManager) Unprepare(claimUID string, pl PreparedDeviceList) error {
+// createCacheFileHostDirectory (re)creates the (empty) host directory of the
+// HAMi-core shared cache file of a claim. Errors are logged only.
+func (m *HAMiCoreManager) createCacheFileHostDirectory(ctx context.Context, path string) {
+	var before string
+	if _, err := os.Stat(path); err == nil {
+		before = "exists"
+	}
+	if m.nvdevlib.dryRunMutation(ctx, auditOpCreateHAMiCacheDirectory, path, before, "created (mode 0777)") {
+		return
+	}
+	t0 := time.Now()
+	var errs []error
+	err := os.RemoveAll(path)
+	if err != nil {
+		klog.FromContext(ctx).Error(err, "Failed to remove host directory for cachefile", "path", path)
+		errs = append(errs, err)
+	}
+	err = os.MkdirAll(path, 0777)
+	if err != nil {
+		klog.FromContext(ctx).Error(err, "Failed to create host directory for cachefile", "path", path)
+		errs = append(errs, err)
+	}
+	err = os.Chmod(path, 0777)
+	if err != nil {
+		klog.FromContext(ctx).Error(err, "Failed to change mode of host directory for cachefile", "path", path)
+		errs = append(errs, err)
+	}
+	m.nvdevlib.auditLog.mutation(ctx, auditOpCreateHAMiCacheDirectory, path, before, "created (mode 0777)", t0, errors.Join(errs...))
+}
+
+func (m *HAMiCoreManager) Unprepare(ctx context.Context, claimUID string, pl PreparedDeviceList) error {
 	path := fmt.Sprintf("%s/vgpu/claims/%s", m.hostHookPath, claimUID)
-	_ = os.RemoveAll(path)
+	if m.nvdevlib.dryRunMutation(ctx, auditOpDeleteHAMiCacheDirectory, path, "", "") {
+		return nil
+	}
+	t0 := time.Now()
+	err := os.RemoveAll(path)
+	m.nvdevlib.auditLog.mutation(ctx, auditOpDeleteHAMiCacheDirectory, path, "", "", t0, err)
 	return nil
 }
 
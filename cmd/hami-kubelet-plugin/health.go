@@ -37,6 +37,10 @@ import (
 	registerapi "k8s.io/kubelet/pkg/apis/pluginregistration/v1"
 )
 
+// healthService checks (a subsystem of) this plugin; it returns an error if
+// the check fails.
+type healthService func(ctx context.Context) error
+
 type healthcheck struct {
 	grpc_health_v1.UnimplementedHealthServer
 
@@ -44,12 +48,20 @@ type healthcheck struct {
 	wg     sync.WaitGroup
 
 	kphelper *kubeletplugin.Helper
+	state    *DeviceState
 
 	regClient registerapi.RegistrationClient
 	draClient drapb.DRAPluginClient
+
+	// By service name. "" and "liveness" fail only if the gRPC services of
+	// the plugin do not respond (and it should be restarted); "readiness" also
+	// fails if the plugin is not (yet) able to prepare claims, or a
+	// background loop is wedged (which a restart may not fix, e.g. a hung
+	// NVML call).
+	services map[string]healthService
 }
 
-func startHealthcheck(ctx context.Context, config *Config, helper *kubeletplugin.Helper) (*healthcheck, error) {
+func startHealthcheck(ctx context.Context, config *Config, helper *kubeletplugin.Helper, state *DeviceState) (*healthcheck, error) {
 	port := config.flags.healthcheckPort
 	if port < 0 {
 		return nil, nil
@@ -95,6 +107,18 @@ func startHealthcheck(ctx context.Context, config *Config, helper *kubeletplugin
 		regClient: registerapi.NewRegistrationClient(regConn),
 		draClient: drapb.NewDRAPluginClient(draConn),
 		kphelper:  helper,
+		state:     state,
+	}
+	healthcheck.services = map[string]healthService{
+		"":           healthcheck.checkLiveness,
+		"liveness":   healthcheck.checkLiveness,
+		"readiness":  healthcheck.checkReadiness,
+		"nvml":       state.checkNVML,
+		"checkpoint": state.checkCheckpoint,
+		"cdi":        state.checkCDI,
+		"mps":        state.checkMPS,
+		"hami-core":  state.checkHAMiCore,
+		"loops":      checkBackgroundLoops,
 	}
 	grpc_health_v1.RegisterHealthServer(server, healthcheck)
 
@@ -120,31 +144,64 @@ func (h *healthcheck) Stop() {
 
 // Check implements [grpc_health_v1.HealthServer].
 func (h *healthcheck) Check(ctx context.Context, req *grpc_health_v1.HealthCheckRequest) (*grpc_health_v1.HealthCheckResponse, error) {
-	knownServices := map[string]struct{}{"": {}, "liveness": {}}
-	if _, known := knownServices[req.GetService()]; !known {
+	service, known := h.services[req.GetService()]
+	if !known {
 		return nil, status.Error(codes.NotFound, "unknown service")
 	}
 
 	status := &grpc_health_v1.HealthCheckResponse{
 		Status: grpc_health_v1.HealthCheckResponse_NOT_SERVING,
 	}
+	if err := service(ctx); err != nil {
+		klog.ErrorS(err, "Health check failed", "service", req.GetService())
+		return status, nil
+	}
+
+	status.Status = grpc_health_v1.HealthCheckResponse_SERVING
+	return status, nil
+}
 
+// checkLiveness checks that the plugin's gRPC services respond.
+func (h *healthcheck) checkLiveness(ctx context.Context) error {
 	info, err := h.regClient.GetInfo(ctx, &registerapi.InfoRequest{})
 	if err != nil {
-		klog.ErrorS(err, "failed to call GetInfo")
-		return status, nil
+		return fmt.Errorf("failed to call GetInfo: %w", err)
 	}
 	klog.V(7).Infof("Health check: successfully invoked GetInfo: %v", info)
 
 	_, err = h.draClient.NodePrepareResources(ctx, &drapb.NodePrepareResourcesRequest{})
 	if err != nil {
-		klog.ErrorS(err, "failed to call NodePrepareResources")
-		return status, nil
+		return fmt.Errorf("failed to call NodePrepareResources: %w", err)
 	}
-
 	klog.V(7).Info("Health check: success: got NodePrepareResourcesResponse for noop request")
-	klog.V(6).Infof("Current kubelet plugin registration status: %s", h.kphelper.RegistrationStatus())
+	return nil
+}
 
-	status.Status = grpc_health_v1.HealthCheckResponse_SERVING
-	return status, nil
+// checkBackgroundLoops checks that no background loop is wedged.
+func checkBackgroundLoops(ctx context.Context) error {
+	return backgroundLoops.check()
+}
+
+// checkReadiness checks liveness, registration with the kubelet, all
+// subsystems and the background loops.
+func (h *healthcheck) checkReadiness(ctx context.Context) error {
+	if err := h.checkLiveness(ctx); err != nil {
+		return err
+	}
+
+	registration := h.kphelper.RegistrationStatus()
+	klog.V(6).Infof("Current kubelet plugin registration status: %s", registration)
+	if registration == nil {
+		return fmt.Errorf("not yet registered with the kubelet")
+	}
+	if !registration.PluginRegistered {
+		return fmt.Errorf("not registered with the kubelet: %s", registration.Error)
+	}
+
+	for _, name := range []string{"nvml", "checkpoint", "cdi", "mps", "hami-core", "loops"} {
+		if err := h.services[name](ctx); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
 }
@@ -0,0 +1,181 @@
+/*
+Copyright 2025 The HAMi Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+	"k8s.io/kubernetes/pkg/kubelet/checkpointmanager/checksum"
+	cperrors "k8s.io/kubernetes/pkg/kubelet/checkpointmanager/errors"
+)
+
+const (
+	// Basename of the file persisting the health history of all devices
+	// across restarts (next to the checkpoint of prepared claims).
+	HealthHistoryCheckpointFileBasename = "health-history.json"
+
+	// Number of records kept per device.
+	maxHealthHistoryLength = 16
+)
+
+// HealthRecord is a health transition of a device.
+type HealthRecord struct {
+	Time time.Time `json:"time"`
+	// Health of the device after the transition.
+	Health HealthStatus `json:"health"`
+	// Short, label-like reason, e.g. "xid-48", "clock-throttle" or
+	// "recovered".
+	Reason string `json:"reason"`
+	Xid    uint64 `json:"xid,omitempty"`
+	// GPU and compute instance the event was reported for (MIG only).
+	GI     *uint32      `json:"gi,omitempty"`
+	CI     *uint32      `json:"ci,omitempty"`
+	Action HealthAction `json:"action,omitempty"`
+}
+
+func (r HealthRecord) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s (reason: %s", r.Health, r.Reason)
+	if r.Xid != 0 {
+		fmt.Fprintf(&b, ", XID: %d", r.Xid)
+	}
+	if r.GI != nil && r.CI != nil {
+		fmt.Fprintf(&b, ", GI: %d, CI: %d", *r.GI, *r.CI)
+	}
+	if r.Action != "" {
+		fmt.Fprintf(&b, ", action: %s", r.Action)
+	}
+	b.WriteString(")")
+	return b.String()
+}
+
+// appendHealthRecord appends the record to the history, dropping the oldest
+// records beyond maxHealthHistoryLength.
+func appendHealthRecord(history []HealthRecord, record HealthRecord) []HealthRecord {
+	history = append(history, record)
+	if len(history) > maxHealthHistoryLength {
+		history = slices.Clone(history[len(history)-maxHealthHistoryLength:])
+	}
+	return history
+}
+
+// HealthHistoryCheckpoint holds the health history of all devices, by
+// canonical device name.
+type HealthHistoryCheckpoint struct {
+	Checksum checksum.Checksum         `json:"checksum"`
+	Devices  map[string][]HealthRecord `json:"devices"`
+}
+
+func (cp *HealthHistoryCheckpoint) MarshalCheckpoint() ([]byte, error) {
+	cp.Checksum = 0
+	out, err := json.Marshal(*cp)
+	if err != nil {
+		return nil, err
+	}
+	cp.Checksum = checksum.New(out)
+	return json.Marshal(*cp)
+}
+
+func (cp *HealthHistoryCheckpoint) UnmarshalCheckpoint(data []byte) error {
+	return json.Unmarshal(data, cp)
+}
+
+func (cp *HealthHistoryCheckpoint) VerifyChecksum() error {
+	ck := cp.Checksum
+	defer func() {
+		cp.Checksum = ck
+	}()
+	cp.Checksum = 0
+	out, err := json.Marshal(*cp)
+	if err != nil {
+		return err
+	}
+	return ck.Verify(out)
+}
+
+// healthHistory returns a pointer to the health history of an allocatable
+// device; nil if not tracked for the device type.
+func (d *AllocatableDevice) healthHistory() *[]HealthRecord {
+	switch d.Type() {
+	case HAMiGpuDeviceType:
+		return &d.HAMiGpu.healthHistory
+	case GpuDeviceType:
+		return &d.Gpu.healthHistory
+	case MigStaticDeviceType:
+		return &d.MigStatic.healthHistory
+	}
+	return nil
+}
+
+// AddHealthRecord appends a record to the health history of an allocatable
+// device, and persists the health history of all devices.
+func (s *DeviceState) AddHealthRecord(d *AllocatableDevice, record HealthRecord) error {
+	s.Lock()
+	history := d.healthHistory()
+	if history == nil {
+		s.Unlock()
+		klog.V(6).Infof("Cannot record health history for device of type: %s", d.Type())
+		return nil
+	}
+	*history = appendHealthRecord(*history, record)
+
+	cp := &HealthHistoryCheckpoint{Devices: make(map[string][]HealthRecord)}
+	for name, device := range s.allocatable {
+		if h := device.healthHistory(); h != nil && len(*h) > 0 {
+			cp.Devices[name] = slices.Clone(*h)
+		}
+	}
+	s.Unlock()
+
+	if err := s.checkpointManager.CreateCheckpoint(HealthHistoryCheckpointFileBasename, cp); err != nil {
+		return fmt.Errorf("unable to persist health history: %w", err)
+	}
+	return nil
+}
+
+// loadHealthHistory restores the health history of the allocatable devices
+// persisted before the last restart. Devices which are gone are ignored.
+func (s *DeviceState) loadHealthHistory() error {
+	cp := &HealthHistoryCheckpoint{}
+	err := s.checkpointManager.GetCheckpoint(HealthHistoryCheckpointFileBasename, cp)
+	if errors.Is(err, cperrors.ErrCheckpointNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("unable to read health history: %w", err)
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	for name, records := range cp.Devices {
+		device, exists := s.allocatable[name]
+		if !exists {
+			continue
+		}
+		if history := device.healthHistory(); history != nil {
+			*history = records
+		}
+	}
+	klog.V(4).Infof("Restored health history of %d device(s)", len(cp.Devices))
+	return nil
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2025 The HAMi Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/kubernetes/pkg/kubelet/checkpointmanager"
+	"k8s.io/utils/ptr"
+)
+
+func TestAppendHealthRecord(t *testing.T) {
+	record := func(i int) HealthRecord {
+		return HealthRecord{Time: time.Unix(int64(i), 0).UTC(), Health: Unhealthy, Reason: "xid-48", Xid: 48}
+	}
+	records := func(first, last int) []HealthRecord {
+		var history []HealthRecord
+		for i := first; i <= last; i++ {
+			history = append(history, record(i))
+		}
+		return history
+	}
+
+	for _, tc := range []struct {
+		name     string
+		history  []HealthRecord
+		record   HealthRecord
+		expected []HealthRecord
+	}{
+		{"empty", nil, record(1), records(1, 1)},
+		{"below limit", records(1, 3), record(4), records(1, 4)},
+		{"at limit", records(1, maxHealthHistoryLength-1), record(maxHealthHistoryLength), records(1, maxHealthHistoryLength)},
+		{"oldest dropped", records(1, maxHealthHistoryLength), record(maxHealthHistoryLength + 1), records(2, maxHealthHistoryLength+1)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, appendHealthRecord(tc.history, tc.record))
+		})
+	}
+}
+
+func TestHealthHistoryCheckpointRoundTrip(t *testing.T) {
+	manager, err := checkpointmanager.NewCheckpointManager(t.TempDir())
+	require.NoError(t, err)
+
+	cp := &HealthHistoryCheckpoint{Devices: map[string][]HealthRecord{
+		"gpu-0": {
+			{Time: time.Unix(100, 0).UTC(), Health: Unhealthy, Reason: "xid-48", Xid: 48, Action: HealthActionRequireReset},
+		},
+		"gpu-1-mig-1g.10gb-19-0": {
+			{Time: time.Unix(200, 0).UTC(), Health: Degraded, Reason: "single-bit-ecc", GI: ptr.To[uint32](1), CI: ptr.To[uint32](0), Action: HealthActionDegrade},
+			{Time: time.Unix(300, 0).UTC(), Health: Healthy, Reason: "recovered"},
+		},
+	}}
+	require.NoError(t, manager.CreateCheckpoint(HealthHistoryCheckpointFileBasename, cp))
+
+	restored := &HealthHistoryCheckpoint{}
+	require.NoError(t, manager.GetCheckpoint(HealthHistoryCheckpointFileBasename, restored))
+	require.Equal(t, cp.Devices, restored.Devices)
+
+	// A modified checkpoint fails the checksum verification.
+	restored.Devices["gpu-0"][0].Reason = "xid-79"
+	require.Error(t, restored.VerifyChecksum())
+}
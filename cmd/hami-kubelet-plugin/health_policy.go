@@ -0,0 +1,539 @@
+/*
+Copyright 2025 The HAMi Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	resourceapi "k8s.io/api/resource/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+)
+
+// HealthAction is what the health monitor does with a device upon a health
+// event (an XID, or another NVML event).
+type HealthAction string
+
+const (
+	// HealthActionIgnore drops the event; the device stays healthy.
+	HealthActionIgnore HealthAction = "Ignore"
+	// HealthActionDegrade keeps the device healthy, but announces it as
+	// degraded (device attribute `degraded`) until no such event has been
+	// seen for a cool-down period.
+	HealthActionDegrade HealthAction = "Degrade"
+	// HealthActionMarkUnhealthy marks the device unhealthy until it passes the
+	// recovery probes after a cool-down period.
+	HealthActionMarkUnhealthy HealthAction = "MarkUnhealthy"
+	// HealthActionMarkUnhealthyFor marks the device unhealthy for the duration
+	// of the rule, after which it is marked healthy again (if it passes the
+	// recovery probes).
+	HealthActionMarkUnhealthyFor HealthAction = "MarkUnhealthyFor"
+	// HealthActionRequireReset marks the device unhealthy; it is not probed
+	// for recovery, and stays unhealthy for the lifetime of this plugin
+	// process.
+	HealthActionRequireReset HealthAction = "RequireReset"
+)
+
+// HealthPolicyFile is the on-disk format of the health policy given by
+// --xid-policy-file (the flag predates events and probes), e.g.:
+//
+//	defaultAction: MarkUnhealthy
+//	rules:
+//	- xids: [13, 31, "60-70"]
+//	  action: Ignore
+//	- xids: [94]
+//	  action: MarkUnhealthyFor
+//	  duration: 10m
+//	- xids: ["119-120"]
+//	  action: RequireReset
+//	  taintEffect: NoSchedule
+//	  failClaims: true
+//	events:
+//	  singleBitEcc:
+//	    action: Degrade
+//	    threshold: 100
+//	  clockThrottle:
+//	    action: Ignore
+//	probes:
+//	  temperature:
+//	    action: MarkUnhealthyFor
+//	    duration: 15m
+//	    threshold: 90
+//	  pcieReplay:
+//	    action: Degrade
+//	    threshold: 1000
+//	    interval: 10m
+//
+// Rules are evaluated in order; the first rule matching an XID wins. Rules of
+// the file take precedence over the XIDs of --additional-xids-to-ignore,
+// which take precedence over the built-in rules (see defaultXidRules). XIDs
+// not matched by any rule get `defaultAction` (MarkUnhealthy if unset, as for
+// any XID not skipped before health policies existed).
+//
+// `events` configures the handling of NVML events other than XIDs; event
+// types not given in the file keep their built-in rule (see
+// defaultEventRules).
+//
+// `probes` configures the periodic health probes (see
+// device_health_probes.go); probes not given in the file keep their built-in
+// rule (see defaultProbeRules). A probe with action Ignore is not run.
+//
+// `taintEffect` is the effect of the device taint announcing an unhealthy
+// device (with the DeviceHealthTaints feature gate). It defaults to NoExecute
+// for RequireReset, and to NoSchedule otherwise.
+//
+// `failClaims` opts in to flagging the prepared claims using a device marked
+// unhealthy by the rule, as configured by --unhealthy-claim-action.
+type HealthPolicyFile struct {
+	DefaultAction HealthAction `json:"defaultAction,omitempty"`
+	Rules         []XidRule    `json:"rules,omitempty"`
+	Events        EventRules   `json:"events,omitempty"`
+	Probes        ProbeRules   `json:"probes,omitempty"`
+}
+
+// HealthRule is the action to take upon a health event.
+type HealthRule struct {
+	Action      HealthAction                  `json:"action"`
+	Duration    metav1.Duration               `json:"duration,omitempty"`
+	TaintEffect resourceapi.DeviceTaintEffect `json:"taintEffect,omitempty"`
+	FailClaims  bool                          `json:"failClaims,omitempty"`
+}
+
+type XidRule struct {
+	Xids []XidRange `json:"xids"`
+	HealthRule
+}
+
+// EventRules holds one rule per (non-XID) NVML event type.
+type EventRules struct {
+	DoubleBitEcc *EventRule `json:"doubleBitEcc,omitempty"`
+	// Applies once the volatile corrected ECC error count of the GPU reaches
+	// the threshold.
+	SingleBitEcc *EventRule `json:"singleBitEcc,omitempty"`
+	PState       *EventRule `json:"pstate,omitempty"`
+	// Applies to clock changes caused by thermal or power slowdown.
+	ClockThrottle *EventRule `json:"clockThrottle,omitempty"`
+	PowerSource   *EventRule `json:"powerSource,omitempty"`
+}
+
+type EventRule struct {
+	HealthRule
+	Threshold uint64 `json:"threshold,omitempty"`
+}
+
+// ProbeRules holds one rule per health probe.
+type ProbeRules struct {
+	// Applies if the GPU does not answer an NVML query in time. A GPU which
+	// fell off the bus is always marked unhealthy until reset.
+	Responsive *ProbeRule `json:"responsive,omitempty"`
+	// Applies if memory pages are pending retirement, or once the number of
+	// retired pages reaches the threshold.
+	RetiredPages *ProbeRule `json:"retiredPages,omitempty"`
+	// Applies if rows are pending remapping, or remapping failed.
+	RemappedRows *ProbeRule `json:"remappedRows,omitempty"`
+	// Applies once the volatile uncorrected ECC error count increased by the
+	// threshold since the last probe.
+	EccErrors *ProbeRule `json:"eccErrors,omitempty"`
+	// Applies once the PCIe replay counter increased by the threshold since
+	// the last probe.
+	PcieReplay *ProbeRule `json:"pcieReplay,omitempty"`
+	// Applies once the GPU temperature reaches the threshold (in degrees C);
+	// the slowdown temperature of the GPU if zero.
+	Temperature *ProbeRule `json:"temperature,omitempty"`
+}
+
+type ProbeRule struct {
+	EventRule
+	// How often to run the probe; the probe interval of the plugin if zero.
+	// The probe does not run more often than that.
+	Interval metav1.Duration `json:"interval,omitempty"`
+}
+
+func (r *ProbeRule) validate() error {
+	if r.Interval.Duration < 0 {
+		return fmt.Errorf("negative interval")
+	}
+	return r.HealthRule.validate()
+}
+
+// XidRange is an inclusive range of XIDs. It is written either as a single
+// number (`48`) or as a string (`"48"`, `"60-70"`).
+type XidRange struct {
+	First uint64
+	Last  uint64
+}
+
+// healthPolicy is the effective, validated policy.
+type healthPolicy struct {
+	rules         []XidRule
+	defaultAction HealthAction
+	// All set.
+	events EventRules
+	probes ProbeRules
+}
+
+// healthPolicyStore holds the current policy and reloads it when the policy file
+// changes.
+type healthPolicyStore struct {
+	path           string
+	additionalXids string
+	policy         atomic.Pointer[healthPolicy]
+
+	// Content of the policy file currently in effect. Only accessed by
+	// reload().
+	mutex   sync.Mutex
+	content []byte
+}
+
+// defaultXidRules are the built-in rules; XIDs not listed mark the device
+// unhealthy (the default action). The actions follow the recommended
+// resolutions of the XID catalog: https://docs.nvidia.com/deploy/xid-errors/
+func defaultXidRules() []XidRule {
+	return []XidRule{
+		{
+			// Application errors: the GPU should still be healthy.
+			HealthRule: HealthRule{Action: HealthActionIgnore},
+			Xids: xids(
+				13,  // Graphics Engine Exception
+				31,  // GPU memory page fault
+				43,  // GPU stopped processing
+				45,  // Preemptive cleanup, due to previous errors
+				68,  // Video processor exception
+				109, // Context Switch Timeout Error
+			),
+		},
+		{
+			// The affected application got terminated; the GPU is expected
+			// to be usable again for new workloads.
+			HealthRule: HealthRule{
+				Action:   HealthActionMarkUnhealthyFor,
+				Duration: metav1.Duration{Duration: 10 * time.Minute},
+			},
+			Xids: xids(
+				94, // Contained ECC error
+			),
+		},
+		{
+			// The GPU keeps working, but should be drained and reset at
+			// the next opportunity.
+			HealthRule: HealthRule{Action: HealthActionDegrade},
+			Xids: xids(
+				63, // ECC page retirement or row remapping recording event
+				92, // High single-bit ECC error rate
+			),
+		},
+		{
+			HealthRule: HealthRule{Action: HealthActionRequireReset},
+			Xids: xids(
+				48,  // Double Bit ECC Error
+				64,  // ECC page retirement or row remapper recording failure
+				74,  // NVLINK Error
+				79,  // GPU has fallen off the bus
+				95,  // Uncontained ECC error
+				119, // GSP RPC Timeout
+				120, // GSP Error
+				140, // Unrecovered ECC Error
+			),
+		},
+	}
+}
+
+// defaultEventRules are the built-in rules for NVML events other than XIDs.
+func defaultEventRules() EventRules {
+	return EventRules{
+		DoubleBitEcc:  &EventRule{HealthRule: HealthRule{Action: HealthActionRequireReset}},
+		SingleBitEcc:  &EventRule{HealthRule: HealthRule{Action: HealthActionDegrade}, Threshold: 1000},
+		PState:        &EventRule{HealthRule: HealthRule{Action: HealthActionIgnore}},
+		ClockThrottle: &EventRule{HealthRule: HealthRule{Action: HealthActionDegrade}},
+		PowerSource:   &EventRule{HealthRule: HealthRule{Action: HealthActionIgnore}},
+	}
+}
+
+// defaultProbeRules are the built-in rules for the health probes.
+func defaultProbeRules() ProbeRules {
+	return ProbeRules{
+		Responsive:   &ProbeRule{EventRule: EventRule{HealthRule: HealthRule{Action: HealthActionMarkUnhealthy}}},
+		RetiredPages: &ProbeRule{EventRule: EventRule{HealthRule: HealthRule{Action: HealthActionRequireReset}, Threshold: 60}},
+		RemappedRows: &ProbeRule{EventRule: EventRule{HealthRule: HealthRule{Action: HealthActionRequireReset}}},
+		EccErrors:    &ProbeRule{EventRule: EventRule{HealthRule: HealthRule{Action: HealthActionMarkUnhealthy}, Threshold: 1}},
+		PcieReplay:   &ProbeRule{EventRule: EventRule{HealthRule: HealthRule{Action: HealthActionDegrade}, Threshold: 100}},
+		Temperature:  &ProbeRule{EventRule: EventRule{HealthRule: HealthRule{Action: HealthActionDegrade}}},
+	}
+}
+
+func xids(ids ...uint64) []XidRange {
+	var ranges []XidRange
+	for _, id := range ids {
+		ranges = append(ranges, XidRange{First: id, Last: id})
+	}
+	return ranges
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (r *XidRange) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		var n uint64
+		if err := json.Unmarshal(data, &n); err != nil {
+			return fmt.Errorf("XID must be a number or a string: %s", data)
+		}
+		*r = XidRange{First: n, Last: n}
+		return nil
+	}
+
+	first, last, isRange := strings.Cut(s, "-")
+	f, err := strconv.ParseUint(strings.TrimSpace(first), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid XID %q: %w", s, err)
+	}
+	l := f
+	if isRange {
+		l, err = strconv.ParseUint(strings.TrimSpace(last), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid XID range %q: %w", s, err)
+		}
+	}
+	if l < f {
+		return fmt.Errorf("invalid XID range %q: end before start", s)
+	}
+	*r = XidRange{First: f, Last: l}
+	return nil
+}
+
+func (r XidRange) contains(xid uint64) bool {
+	return xid >= r.First && xid <= r.Last
+}
+
+func (r XidRule) validate() error {
+	if len(r.Xids) == 0 {
+		return fmt.Errorf("no XIDs")
+	}
+	return r.HealthRule.validate()
+}
+
+func (r HealthRule) validate() error {
+	switch r.Action {
+	case HealthActionIgnore, HealthActionDegrade, HealthActionMarkUnhealthy, HealthActionRequireReset:
+	case HealthActionMarkUnhealthyFor:
+		if r.Duration.Duration <= 0 {
+			return fmt.Errorf("action %s requires a positive duration", r.Action)
+		}
+	default:
+		return fmt.Errorf("unknown action %q", r.Action)
+	}
+	switch r.TaintEffect {
+	case "", resourceapi.DeviceTaintEffectNoSchedule, resourceapi.DeviceTaintEffectNoExecute:
+	default:
+		return fmt.Errorf("unknown taint effect %q", r.TaintEffect)
+	}
+	return nil
+}
+
+func (r HealthRule) taintEffect() resourceapi.DeviceTaintEffect {
+	if r.TaintEffect != "" {
+		return r.TaintEffect
+	}
+	if r.Action == HealthActionRequireReset {
+		return resourceapi.DeviceTaintEffectNoExecute
+	}
+	return resourceapi.DeviceTaintEffectNoSchedule
+}
+
+// ruleFor returns the first rule matching the XID; the default action if there
+// is none.
+func (p *healthPolicy) ruleFor(xid uint64) HealthRule {
+	for _, rule := range p.rules {
+		for _, r := range rule.Xids {
+			if r.contains(xid) {
+				return rule.HealthRule
+			}
+		}
+	}
+	return HealthRule{Action: p.defaultAction}
+}
+
+// newHealthPolicy builds the effective policy from the policy file content (may
+// be empty), the comma-separated list of additional XIDs to ignore, and the
+// built-in rules -- in that order of precedence.
+func newHealthPolicy(content []byte, additionalXids string) (*healthPolicy, error) {
+	var file HealthPolicyFile
+	if err := yaml.UnmarshalStrict(content, &file); err != nil {
+		return nil, fmt.Errorf("error parsing health policy: %w", err)
+	}
+
+	policy := &healthPolicy{defaultAction: HealthActionMarkUnhealthy}
+	switch file.DefaultAction {
+	case "":
+	case HealthActionIgnore, HealthActionDegrade, HealthActionMarkUnhealthy, HealthActionRequireReset:
+		policy.defaultAction = file.DefaultAction
+	default:
+		return nil, fmt.Errorf("invalid default action %q", file.DefaultAction)
+	}
+
+	for i, rule := range file.Rules {
+		if err := rule.validate(); err != nil {
+			return nil, fmt.Errorf("invalid XID rule %d: %w", i, err)
+		}
+	}
+
+	policy.rules = append(policy.rules, file.Rules...)
+	if ignored := getAdditionalXids(additionalXids); len(ignored) > 0 {
+		policy.rules = append(policy.rules, XidRule{HealthRule: HealthRule{Action: HealthActionIgnore}, Xids: xids(ignored...)})
+	}
+	policy.rules = append(policy.rules, defaultXidRules()...)
+
+	policy.events = defaultEventRules()
+	for _, e := range []struct {
+		name       string
+		effective  **EventRule
+		configured *EventRule
+	}{
+		{"doubleBitEcc", &policy.events.DoubleBitEcc, file.Events.DoubleBitEcc},
+		{"singleBitEcc", &policy.events.SingleBitEcc, file.Events.SingleBitEcc},
+		{"pstate", &policy.events.PState, file.Events.PState},
+		{"clockThrottle", &policy.events.ClockThrottle, file.Events.ClockThrottle},
+		{"powerSource", &policy.events.PowerSource, file.Events.PowerSource},
+	} {
+		if e.configured == nil {
+			continue
+		}
+		if err := e.configured.validate(); err != nil {
+			return nil, fmt.Errorf("invalid rule for event %s: %w", e.name, err)
+		}
+		*e.effective = e.configured
+	}
+
+	policy.probes = defaultProbeRules()
+	for _, p := range []struct {
+		name       string
+		effective  **ProbeRule
+		configured *ProbeRule
+	}{
+		{"responsive", &policy.probes.Responsive, file.Probes.Responsive},
+		{"retiredPages", &policy.probes.RetiredPages, file.Probes.RetiredPages},
+		{"remappedRows", &policy.probes.RemappedRows, file.Probes.RemappedRows},
+		{"eccErrors", &policy.probes.EccErrors, file.Probes.EccErrors},
+		{"pcieReplay", &policy.probes.PcieReplay, file.Probes.PcieReplay},
+		{"temperature", &policy.probes.Temperature, file.Probes.Temperature},
+	} {
+		if p.configured == nil {
+			continue
+		}
+		if err := p.configured.validate(); err != nil {
+			return nil, fmt.Errorf("invalid rule for probe %s: %w", p.name, err)
+		}
+		*p.effective = p.configured
+	}
+	return policy, nil
+}
+
+// newHealthPolicyStore loads the initial policy. An invalid policy file is an
+// error here; later on, an invalid file is logged and the previous policy
+// stays in effect.
+func newHealthPolicyStore(path, additionalXids string) (*healthPolicyStore, error) {
+	s := &healthPolicyStore{
+		path:           path,
+		additionalXids: additionalXids,
+	}
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *healthPolicyStore) get() *healthPolicy {
+	return s.policy.Load()
+}
+
+func (s *healthPolicyStore) reload() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var content []byte
+	if s.path != "" {
+		var err error
+		content, err = os.ReadFile(s.path)
+		if err != nil {
+			return fmt.Errorf("error reading health policy file: %w", err)
+		}
+	}
+	if s.policy.Load() != nil && bytes.Equal(content, s.content) {
+		return nil
+	}
+
+	policy, err := newHealthPolicy(content, s.additionalXids)
+	if err != nil {
+		return fmt.Errorf("%s: %w", s.path, err)
+	}
+	s.policy.Store(policy)
+	s.content = content
+	klog.Infof("Loaded health policy (file: %q, rules: %d, default action: %s)", s.path, len(policy.rules), policy.defaultAction)
+	return nil
+}
+
+// watch reloads the policy upon changes of the policy file until the context
+// is canceled. The parent directory is watched (and not the file itself): a
+// mounted ConfigMap is updated by replacing a symlink in that directory.
+func (s *healthPolicyStore) watch(ctx context.Context) {
+	if s.path == "" {
+		return
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		klog.Errorf("Unable to watch health policy file, changes require a restart: %s", err)
+		return
+	}
+	defer func() { _ = watcher.Close() }()
+
+	if err := watcher.Add(filepath.Dir(s.path)); err != nil {
+		klog.Errorf("Unable to watch health policy file, changes require a restart: %s", err)
+		return
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			klog.V(6).Infof("health policy directory event: %s", event)
+			if err := s.reload(); err != nil {
+				klog.Warningf("Keeping previous health policy: %s", err)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			klog.Warningf("Error watching health policy file: %s", err)
+		}
+	}
+}
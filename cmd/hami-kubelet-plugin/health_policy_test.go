@@ -0,0 +1,156 @@
+/*
+Copyright 2025 The HAMi Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestHealthPolicyXidPrecedence(t *testing.T) {
+	// 48 and 79 have a built-in RequireReset rule, 13 is ignored by a built-in
+	// rule.
+	file := []byte(`
+rules:
+- xids: [13]
+  action: MarkUnhealthy
+- xids: ["48-49"]
+  action: Degrade
+`)
+
+	policy, err := newHealthPolicy(file, "48, 13, 79")
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		xid      uint64
+		expected HealthAction
+	}{
+		// The rules of the file win over the XIDs to ignore.
+		{13, HealthActionMarkUnhealthy},
+		{48, HealthActionDegrade},
+		// The XIDs to ignore win over the built-in rules.
+		{79, HealthActionIgnore},
+		// Built-in rules apply to XIDs configured nowhere else.
+		{74, HealthActionRequireReset},
+		{31, HealthActionIgnore},
+		// XIDs matched by no rule get the default action.
+		{1000, HealthActionMarkUnhealthy},
+	} {
+		require.Equal(t, tc.expected, policy.ruleFor(tc.xid).Action, "XID %d", tc.xid)
+	}
+
+	policy, err = newHealthPolicy(nil, "")
+	require.NoError(t, err)
+	require.Equal(t, HealthActionRequireReset, policy.ruleFor(79).Action)
+	require.Equal(t, HealthActionMarkUnhealthy, policy.ruleFor(1000).Action)
+}
+
+func TestXidRangeUnmarshalJSON(t *testing.T) {
+	for _, tc := range []struct {
+		input    string
+		expected XidRange
+		err      bool
+	}{
+		{input: `48`, expected: XidRange{First: 48, Last: 48}},
+		{input: `"48"`, expected: XidRange{First: 48, Last: 48}},
+		{input: `"60-70"`, expected: XidRange{First: 60, Last: 70}},
+		{input: `" 60 - 70 "`, expected: XidRange{First: 60, Last: 70}},
+		{input: `"70-70"`, expected: XidRange{First: 70, Last: 70}},
+		{input: `"70-60"`, err: true},
+		{input: `"60-"`, err: true},
+		{input: `"x"`, err: true},
+		{input: `-1`, err: true},
+		{input: `[48]`, err: true},
+	} {
+		var r XidRange
+		err := json.Unmarshal([]byte(tc.input), &r)
+		if tc.err {
+			require.Error(t, err, "input %s", tc.input)
+			continue
+		}
+		require.NoError(t, err, "input %s", tc.input)
+		require.Equal(t, tc.expected, r, "input %s", tc.input)
+	}
+}
+
+func TestNewHealthPolicyValidation(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		content string
+		err     bool
+	}{
+		{name: "empty"},
+		{
+			name:    "complete",
+			content: "defaultAction: MarkUnhealthy\nrules:\n- xids: [13, \"60-70\"]\n  action: MarkUnhealthyFor\n  duration: 10m\n  taintEffect: NoExecute\nevents:\n  singleBitEcc:\n    action: Degrade\n    threshold: 100\nprobes:\n  temperature:\n    action: Ignore\n    interval: 1m\n",
+		},
+		{name: "unknown default action", content: "defaultAction: Reboot\n", err: true},
+		{name: "unknown field", content: "defaultActions: Ignore\n", err: true},
+		{name: "rule without XIDs", content: "rules:\n- action: Ignore\n", err: true},
+		{name: "unknown action", content: "rules:\n- xids: [13]\n  action: Reboot\n", err: true},
+		{name: "timed rule without duration", content: "rules:\n- xids: [13]\n  action: MarkUnhealthyFor\n", err: true},
+		{name: "unknown taint effect", content: "rules:\n- xids: [13]\n  action: Ignore\n  taintEffect: Evict\n", err: true},
+		{name: "invalid XID range", content: "rules:\n- xids: [\"70-60\"]\n  action: Ignore\n", err: true},
+		{name: "invalid event rule", content: "events:\n  pstate:\n    action: Reboot\n", err: true},
+		{name: "negative probe interval", content: "probes:\n  pcieReplay:\n    action: Degrade\n    interval: -1m\n", err: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := newHealthPolicy([]byte(tc.content), "")
+			if tc.err {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestHealthPolicyRuleFor(t *testing.T) {
+	policy, err := newHealthPolicy([]byte(`
+defaultAction: Degrade
+rules:
+- xids: ["60-70"]
+  action: MarkUnhealthyFor
+  duration: 5m
+- xids: [65]
+  action: Ignore
+`), "")
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		xid      uint64
+		expected HealthRule
+	}{
+		// The first matching rule wins.
+		{60, HealthRule{Action: HealthActionMarkUnhealthyFor, Duration: metav1.Duration{Duration: 5 * time.Minute}}},
+		{65, HealthRule{Action: HealthActionMarkUnhealthyFor, Duration: metav1.Duration{Duration: 5 * time.Minute}}},
+		{70, HealthRule{Action: HealthActionMarkUnhealthyFor, Duration: metav1.Duration{Duration: 5 * time.Minute}}},
+		// Built-in rules.
+		{13, HealthRule{Action: HealthActionIgnore}},
+		{94, HealthRule{Action: HealthActionMarkUnhealthyFor, Duration: metav1.Duration{Duration: 10 * time.Minute}}},
+		{48, HealthRule{Action: HealthActionRequireReset}},
+		// The default action.
+		{59, HealthRule{Action: HealthActionDegrade}},
+		{71, HealthRule{Action: HealthActionDegrade}},
+	} {
+		require.Equal(t, tc.expected, policy.ruleFor(tc.xid), "XID %d", tc.xid)
+	}
+}
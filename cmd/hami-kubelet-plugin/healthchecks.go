@@ -0,0 +1,212 @@
+/*
+Copyright 2025 The HAMi Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"k8s.io/klog/v2"
+)
+
+// Checks of individual subsystems of this plugin, served as named services of
+// the gRPC healthcheck (see health.go).
+
+const (
+	// A checkpoint read (including lock acquisition) taking longer than this
+	// fails the checkpoint check.
+	checkpointCheckMaxLatency = 5 * time.Second
+
+	// Names of the background loops reporting heartbeats.
+	loopDeviceHealthMonitor = "device-health-monitor"
+	loopClaimCleanup        = "claim-cleanup"
+	loopCDISpecGC           = "cdi-spec-gc"
+	loopMIGReconcile        = "mig-reconcile"
+	loopGPUTelemetry        = "gpu-telemetry"
+)
+
+// loopHeartbeats tracks the liveness of the background loops of this plugin:
+// a loop is wedged if it did not report a heartbeat in time.
+type loopHeartbeats struct {
+	sync.Mutex
+	loops map[string]heartbeat
+}
+
+type heartbeat struct {
+	last time.Time
+	// The next heartbeat is due within this time.
+	timeout time.Duration
+}
+
+var backgroundLoops = &loopHeartbeats{loops: make(map[string]heartbeat)}
+
+// beat records a heartbeat of a loop; the next one is due within timeout.
+func (h *loopHeartbeats) beat(name string, timeout time.Duration) {
+	h.Lock()
+	defer h.Unlock()
+	h.loops[name] = heartbeat{last: time.Now(), timeout: timeout}
+}
+
+// stop stops tracking a loop which returned.
+func (h *loopHeartbeats) stop(name string) {
+	h.Lock()
+	defer h.Unlock()
+	delete(h.loops, name)
+}
+
+// check returns an error naming the loops whose heartbeat is overdue.
+func (h *loopHeartbeats) check() error {
+	h.Lock()
+	defer h.Unlock()
+
+	var wedged []string
+	for name, hb := range h.loops {
+		if since := time.Since(hb.last); since > hb.timeout {
+			wedged = append(wedged, fmt.Sprintf("%s (last heartbeat %s ago)", name, since.Round(time.Second)))
+		}
+	}
+	if len(wedged) > 0 {
+		sort.Strings(wedged)
+		return fmt.Errorf("background loop(s) wedged: %v", wedged)
+	}
+	return nil
+}
+
+// singleFlightCheck runs a check which may hang (e.g. in an NVML call) with at
+// most one run in flight: a check requested while the previous run has not
+// returned yet waits for that run instead of starting another one.
+type singleFlightCheck struct {
+	sync.Mutex
+	running *checkRun
+}
+
+type checkRun struct {
+	started time.Time
+	// Closed once f returned.
+	done chan struct{}
+	err  error
+}
+
+var nvmlCheck = &singleFlightCheck{}
+
+// run returns the result of the run in flight, or of a new run of f; an error
+// if the context is done first.
+func (c *singleFlightCheck) run(ctx context.Context, f func() error) error {
+	c.Lock()
+	r := c.running
+	if r == nil {
+		r = &checkRun{started: time.Now(), done: make(chan struct{})}
+		c.running = r
+		go func() {
+			r.err = f()
+			c.Lock()
+			c.running = nil
+			c.Unlock()
+			close(r.done)
+		}()
+	}
+	c.Unlock()
+
+	select {
+	case <-r.done:
+		return r.err
+	case <-ctx.Done():
+		return fmt.Errorf("no response, still pending (started %s ago): %w", time.Since(r.started).Round(time.Second), ctx.Err())
+	}
+}
+
+// checkNVML checks that NVML responds.
+func (s *DeviceState) checkNVML(ctx context.Context) error {
+	nvmllib := s.nvdevlib.nvmllib
+	return nvmlCheck.run(ctx, func() error {
+		if ret := nvmllib.Init(); ret != nvml.SUCCESS {
+			return fmt.Errorf("error initializing NVML: %v", ret)
+		}
+		defer func() {
+			_ = nvmllib.Shutdown()
+		}()
+		count, ret := nvmllib.DeviceGetCount()
+		if ret != nvml.SUCCESS {
+			return fmt.Errorf("error getting device count: %v", ret)
+		}
+		klog.V(7).Infof("Health check: NVML reports %d device(s)", count)
+		return nil
+	})
+}
+
+// checkCheckpoint checks that the checkpoint can be read, and that acquiring
+// the checkpoint lock does not take too long.
+func (s *DeviceState) checkCheckpoint(ctx context.Context) error {
+	t0 := time.Now()
+	if _, err := s.getCheckpoint(ctx); err != nil {
+		return fmt.Errorf("error reading checkpoint: %w", err)
+	}
+	latency := time.Since(t0)
+	klog.V(7).Infof("Health check: checkpoint read in %s", latency)
+	if latency > checkpointCheckMaxLatency {
+		return fmt.Errorf("reading checkpoint took %s (more than %s)", latency, checkpointCheckMaxLatency)
+	}
+	return nil
+}
+
+// checkCDI checks that CDI spec files can be written.
+func (s *DeviceState) checkCDI(ctx context.Context) error {
+	return checkDirWritable(s.cdi.cdiRoot)
+}
+
+// checkMPS checks that MPS control daemons can be started: the template can
+// be read, and control files can be written. Passes if MPS is not enabled.
+func (s *DeviceState) checkMPS(ctx context.Context) error {
+	if s.mpsManager == nil {
+		return nil
+	}
+	if _, err := os.Stat(s.mpsManager.templatePath); err != nil {
+		return fmt.Errorf("MPS control daemon template: %w", err)
+	}
+	if err := os.MkdirAll(s.mpsManager.controlFilesRoot, 0750); err != nil {
+		return fmt.Errorf("error creating MPS control files root: %w", err)
+	}
+	return checkDirWritable(s.mpsManager.controlFilesRoot)
+}
+
+// checkHAMiCore checks that HAMi-core can be injected into containers.
+// Passes if HAMi-core is not enabled.
+func (s *DeviceState) checkHAMiCore(ctx context.Context) error {
+	if s.hamiCoreManager == nil {
+		return nil
+	}
+	lib := filepath.Join(s.hamiCoreManager.hostHookPath, "vgpu", "libvgpu.so")
+	if _, err := os.Stat(lib); err != nil {
+		return fmt.Errorf("HAMi-core library: %w", err)
+	}
+	return nil
+}
+
+func checkDirWritable(dir string) error {
+	f, err := os.CreateTemp(dir, ".healthcheck-*")
+	if err != nil {
+		return fmt.Errorf("directory %s is not writable: %w", dir, err)
+	}
+	return errors.Join(f.Close(), os.Remove(f.Name()))
+}
@@ -0,0 +1,246 @@
+/*
+Copyright 2025 The HAMi Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+// The hardware inventory of this node (unlike ResourceSlices, which only show
+// what is allocatable right now) is published as a ConfigMap in the driver
+// namespace, owned by the Node, for fleet dashboards. It is updated upon
+// changes of device health.
+
+const (
+	inventoryConfigMapPrefix = "hami-gpu-inventory-"
+	inventoryDataKey         = "inventory.json"
+	// Label selecting the inventory ConfigMaps, with the node as value.
+	inventoryNodeLabel = DriverName + "/inventory-node"
+
+	inventoryPublishTimeout = 30 * time.Second
+)
+
+type NodeInventory struct {
+	Node              string         `json:"node"`
+	DriverVersion     string         `json:"driverVersion"`
+	CUDADriverVersion string         `json:"cudaDriverVersion"`
+	GPUs              []GPUInventory `json:"gpus"`
+}
+
+type GPUInventory struct {
+	Minor                 int    `json:"minor"`
+	UUID                  string `json:"uuid"`
+	ProductName           string `json:"productName"`
+	Brand                 string `json:"brand"`
+	Architecture          string `json:"architecture"`
+	CUDAComputeCapability string `json:"cudaComputeCapability"`
+	MemoryBytes           uint64 `json:"memoryBytes"`
+	PCIeBusID             string `json:"pcieBusID"`
+	PCIeRoot              string `json:"pcieRoot,omitempty"`
+	AddressingMode        string `json:"addressingMode,omitempty"`
+	MIGEnabled            bool   `json:"migEnabled"`
+	// MIG profiles supported by the GPU (with DynamicMIG, all of them can be
+	// allocated).
+	MIGProfiles []MIGProfileInventory `json:"migProfiles,omitempty"`
+	VfioCapable bool                  `json:"vfioCapable"`
+	// The allocatable devices on this GPU.
+	Devices []DeviceInventory `json:"devices"`
+}
+
+type MIGProfileInventory struct {
+	Name string `json:"name"`
+	// Start indices of the possible placements.
+	PlacementStarts []uint32 `json:"placementStarts"`
+}
+
+type DeviceInventory struct {
+	Name          string         `json:"name"`
+	Type          string         `json:"type"`
+	Healthy       bool           `json:"healthy"`
+	Degraded      bool           `json:"degraded"`
+	HealthHistory []HealthRecord `json:"healthHistory,omitempty"`
+}
+
+// inventoryGpuInfo returns the properties of the GPU that the allocatable
+// devices (all of the same GPU) are on.
+func inventoryGpuInfo(devices AllocatableDevices) *GpuInfo {
+	for _, name := range slices.Sorted(maps.Keys(devices)) {
+		device := devices[name]
+		switch device.Type() {
+		case HAMiGpuDeviceType:
+			return &device.HAMiGpu.GpuInfo
+		case GpuDeviceType:
+			return device.Gpu
+		case MigDynamicDeviceType:
+			return device.MigDynamic.Parent
+		case MigStaticDeviceType:
+			return device.MigStatic.parent
+		case VfioDeviceType:
+			return device.Vfio.parent
+		}
+	}
+	return nil
+}
+
+// Inventory returns the hardware inventory of this node.
+func (s *DeviceState) Inventory() *NodeInventory {
+	s.Lock()
+	defer s.Unlock()
+
+	inventory := &NodeInventory{
+		Node: s.config.flags.nodeName,
+		GPUs: []GPUInventory{},
+	}
+	for _, minor := range slices.Sorted(maps.Keys(s.perGPUAllocatable)) {
+		devices := s.perGPUAllocatable[minor]
+		gpu := inventoryGpuInfo(devices)
+		if gpu == nil {
+			continue
+		}
+		inventory.DriverVersion = gpu.driverVersion
+		inventory.CUDADriverVersion = gpu.cudaDriverVersion
+
+		g := GPUInventory{
+			Minor:                 gpu.minor,
+			UUID:                  gpu.UUID,
+			ProductName:           gpu.productName,
+			Brand:                 gpu.brand,
+			Architecture:          gpu.architecture,
+			CUDAComputeCapability: gpu.cudaComputeCapability,
+			MemoryBytes:           gpu.memoryBytes,
+			PCIeBusID:             gpu.pcieBusID,
+			MIGEnabled:            gpu.migEnabled,
+			VfioCapable:           gpu.vfioEnabled,
+		}
+		if gpu.pcieRootAttr != nil && gpu.pcieRootAttr.Value.StringValue != nil {
+			g.PCIeRoot = *gpu.pcieRootAttr.Value.StringValue
+		}
+		if gpu.addressingMode != nil {
+			g.AddressingMode = *gpu.addressingMode
+		}
+		for _, profile := range gpu.migProfiles {
+			p := MIGProfileInventory{Name: profile.String()}
+			for _, placement := range profile.placements {
+				p.PlacementStarts = append(p.PlacementStarts, placement.Start)
+			}
+			g.MIGProfiles = append(g.MIGProfiles, p)
+		}
+		for _, name := range slices.Sorted(maps.Keys(devices)) {
+			device := devices[name]
+			d := DeviceInventory{
+				Name:     name,
+				Type:     device.Type(),
+				Healthy:  device.IsHealthy(),
+				Degraded: device.IsDegraded(),
+			}
+			if history := device.healthHistory(); history != nil {
+				d.HealthHistory = slices.Clone(*history)
+			}
+			g.Devices = append(g.Devices, d)
+		}
+		inventory.GPUs = append(inventory.GPUs, g)
+	}
+	return inventory
+}
+
+// publishInventory creates or updates the inventory ConfigMap of this node,
+// if its content changed. Errors are logged only: the inventory is
+// informational.
+func (d *driver) publishInventory(ctx context.Context) {
+	if !d.state.config.flags.publishInventory {
+		return
+	}
+	d.inventoryMutex.Lock()
+	defer d.inventoryMutex.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, inventoryPublishTimeout)
+	defer cancel()
+	if err := d.updateInventoryConfigMap(ctx, d.state.Inventory()); err != nil {
+		klog.Warningf("Unable to publish GPU inventory: %v", err)
+	}
+}
+
+func (d *driver) updateInventoryConfigMap(ctx context.Context, inventory *NodeInventory) error {
+	data, err := json.MarshalIndent(inventory, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling inventory: %w", err)
+	}
+
+	config := d.state.config
+	client := config.clientsets.Core.CoreV1().ConfigMaps(config.flags.namespace)
+	name := inventoryConfigMapPrefix + config.flags.nodeName
+
+	if config.flags.dryRun {
+		klog.V(4).InfoS("Dry-run: not publishing GPU inventory", "configMap", klog.KRef(config.flags.namespace, name), "inventory", string(data))
+		return nil
+	}
+
+	cm, err := client.Get(ctx, name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		// Owned by the Node: deleted along with it.
+		node, err := config.clientsets.Core.CoreV1().Nodes().Get(ctx, config.flags.nodeName, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("error getting node: %w", err)
+		}
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: config.flags.namespace,
+				Labels:    map[string]string{inventoryNodeLabel: config.flags.nodeName},
+				OwnerReferences: []metav1.OwnerReference{{
+					APIVersion: "v1",
+					Kind:       "Node",
+					Name:       node.Name,
+					UID:        node.UID,
+				}},
+			},
+			Data: map[string]string{inventoryDataKey: string(data)},
+		}
+		if _, err := client.Create(ctx, cm, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("error creating ConfigMap %s: %w", name, err)
+		}
+		klog.V(4).Infof("Published GPU inventory in ConfigMap %s/%s", config.flags.namespace, name)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error getting ConfigMap %s: %w", name, err)
+	}
+
+	if cm.Data[inventoryDataKey] == string(data) {
+		return nil
+	}
+	cm = cm.DeepCopy()
+	if cm.Data == nil {
+		cm.Data = make(map[string]string)
+	}
+	cm.Data[inventoryDataKey] = string(data)
+	if _, err := client.Update(ctx, cm, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("error updating ConfigMap %s: %w", name, err)
+	}
+	klog.V(4).Infof("Updated GPU inventory in ConfigMap %s/%s", config.flags.namespace, name)
+	return nil
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2025 The HAMi Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+)
+
+// Keys of structured log messages. Log-based tooling (and alerts) rely on
+// them: do not log claims or devices under other keys, or as part of the
+// message.
+const (
+	// A claim reference (namespace/name).
+	logKeyClaim    = "claim"
+	logKeyClaimUID = "claimUID"
+	// The canonical name of an allocatable or prepared device.
+	logKeyDevice  = "device"
+	logKeyGPUUUID = "gpuUUID"
+	logKeyMIGUUID = "migUUID"
+	// A timed step of preparing or unpreparing a claim (in combination with
+	// logKeyDuration).
+	logKeyPhase    = "phase"
+	logKeyDuration = "durationSeconds"
+)
+
+// withClaimLogger returns a context carrying both the claim UID (for spans,
+// see withClaimUID) and a logger with the claim keys: messages logged with
+// klog.FromContext() of it (and of derived contexts) are attributed to the
+// claim.
+func withClaimLogger(ctx context.Context, namespace, name string, uid types.UID) context.Context {
+	if current, ok := ctx.Value(claimUIDKey{}).(types.UID); ok && current == uid {
+		// Do not log the claim keys twice.
+		return ctx
+	}
+	logger := klog.FromContext(ctx).WithValues(logKeyClaim, klog.KRef(namespace, name), logKeyClaimUID, uid)
+	return klog.NewContext(withClaimUID(ctx, uid), logger)
+}
+
+// logPhase logs the time passed since t0 as duration of the given phase.
+func logPhase(logger klog.Logger, level int, phase string, t0 time.Time) {
+	logger.V(level).Info("Phase done", logKeyPhase, phase, logKeyDuration, time.Since(t0).Seconds())
+}
+
+// deviceLogValues returns the keys identifying an allocatable device, for
+// logger.WithValues().
+func deviceLogValues(device *AllocatableDevice) []any {
+	values := []any{logKeyDevice, device.CanonicalName()}
+	switch device.Type() {
+	case HAMiGpuDeviceType, GpuDeviceType, VfioDeviceType:
+		values = append(values, logKeyGPUUUID, device.UUID())
+	case MigStaticDeviceType:
+		values = append(values, logKeyMIGUUID, device.UUID())
+	}
+	return values
+}
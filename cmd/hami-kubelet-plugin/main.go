@@ -23,10 +23,13 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/urfave/cli/v2"
 
+	"k8s.io/client-go/tools/record"
 	"k8s.io/component-base/logs"
 	"k8s.io/dynamic-resource-allocation/kubeletplugin"
 	"k8s.io/klog/v2"
@@ -58,13 +61,44 @@ type Flags struct {
 	kubeletRegistrarDirectoryPath string
 	kubeletPluginsDirectoryPath   string
 	healthcheckPort               int
+	metricsPort                   int
+	tracingEndpoint               string
+	tracingSamplingRatio          float64
+	debugAddress                  string
+	debugDumpDir                  string
+	publishInventory              bool
+	dryRun                        bool
+	dryRunDir                     string
+	dryRunPublishResourceSlices   bool
+	auditLogPath                  string
+	auditLogMaxSizeMB             int
+	auditLogMaxBackups            int
 	klogVerbosity                 int
 	additionalXidsToIgnore        string
+	xidPolicyFile                 string
+	deviceHealthRecoveryInterval  time.Duration
+	deviceHealthRecoveryCooldown  time.Duration
+	deviceHealthProbeInterval     time.Duration
+	unhealthyClaimAction          string
+	claimCleanupMode              string
+	claimCleanupDryRun            bool
+	claimCleanupInterval          time.Duration
+	claimCleanupStartJitter       time.Duration
+
+	claimCleanupMaxUnprepareAttempts int
+	migReconcileInterval             time.Duration
+	gpuTelemetryInterval             time.Duration
+	migReconcileTeardown             bool
 }
 
 type Config struct {
-	flags      *Flags
-	clientsets pkgflags.ClientSets
+	flags         *Flags
+	clientsets    pkgflags.ClientSets
+	eventRecorder record.EventRecorder
+	// Nil unless an audit log path is configured.
+	auditLog *auditLogger
+	// Values of all flags, by name (for the status API).
+	effectiveFlags map[string]string
 }
 
 func (c Config) DriverPluginPath() string {
@@ -86,8 +120,7 @@ func newApp() *cli.App {
 	cliFlags := []cli.Flag{
 		&cli.StringFlag{
 			Name:        "node-name",
-			Usage:       "The name of the node to be worked on.",
-			Required:    true,
+			Usage:       "The name of the node to be worked on (required).",
 			Destination: &flags.nodeName,
 			EnvVars:     []string{"NODE_NAME"},
 		},
@@ -128,8 +161,7 @@ func newApp() *cli.App {
 		},
 		&cli.StringFlag{
 			Name:        "image-name",
-			Usage:       "The full image name to use for rendering templates.",
-			Required:    true,
+			Usage:       "The full image name to use for rendering templates (required).",
 			Destination: &flags.imageName,
 			EnvVars:     []string{"IMAGE_NAME"},
 		},
@@ -154,6 +186,84 @@ func newApp() *cli.App {
 			Destination: &flags.healthcheckPort,
 			EnvVars:     []string{"HEALTHCHECK_PORT"},
 		},
+		&cli.IntFlag{
+			Name:        "metrics-port",
+			Usage:       "Port to serve Prometheus metrics on (at /metrics). When zero, a random port is allocated. When negative, metrics are not served.",
+			Value:       -1,
+			Destination: &flags.metricsPort,
+			EnvVars:     []string{"METRICS_PORT"},
+		},
+		&cli.StringFlag{
+			Name:        "tracing-endpoint",
+			Usage:       "OTLP (gRPC) endpoint URL to export traces of claim preparation and unpreparation to, e.g. 'http://otel-collector:4317'. Tracing is disabled if empty.",
+			Destination: &flags.tracingEndpoint,
+			EnvVars:     []string{"TRACING_ENDPOINT"},
+		},
+		&cli.Float64Flag{
+			Name:        "tracing-sampling-ratio",
+			Usage:       "Fraction of traces to sample (0 to 1), if tracing is enabled.",
+			Value:       1,
+			Destination: &flags.tracingSamplingRatio,
+			EnvVars:     []string{"TRACING_SAMPLING_RATIO"},
+		},
+		&cli.StringFlag{
+			Name:        "debug-address",
+			Usage:       "Address (host:port) to serve debug endpoints on: pprof profiles, goroutine dumps, the checkpoint and the in-memory device state. Disabled if empty. Do not expose beyond localhost.",
+			Destination: &flags.debugAddress,
+			EnvVars:     []string{"DEBUG_ADDRESS"},
+		},
+		&cli.StringFlag{
+			Name:        "debug-dump-dir",
+			Usage:       "Directory to write goroutine stack dumps to upon SIGUSR2.",
+			Value:       "/tmp",
+			Destination: &flags.debugDumpDir,
+			EnvVars:     []string{"DEBUG_DUMP_DIR"},
+		},
+		&cli.BoolFlag{
+			Name:        "publish-inventory",
+			Usage:       "Publish the GPU hardware inventory of this node (GPU properties, MIG profiles, devices and their health history) in a ConfigMap in the driver namespace.",
+			Destination: &flags.publishInventory,
+			EnvVars:     []string{"PUBLISH_INVENTORY"},
+		},
+		&cli.BoolFlag{
+			Name:        "dry-run",
+			Usage:       "Validate the plugin on this node without mutating devices: MIG devices and MIG mode, compute mode, time slice, vfio driver binding, HAMi-core cache directories and MPS control daemons are only logged, as are the node condition, the flagging of claims using unhealthy devices and the GPU inventory ConfigMap. CDI specs and the checkpoint are written to --dry-run-dir. Replaces (does not run alongside) the plugin normally running on the node.",
+			Destination: &flags.dryRun,
+			EnvVars:     []string{"DRY_RUN"},
+		},
+		&cli.StringFlag{
+			Name:        "dry-run-dir",
+			Usage:       "Scratch directory for CDI specs and the checkpoint in dry-run mode.",
+			Value:       "/tmp/hami-kubelet-plugin-dry-run",
+			Destination: &flags.dryRunDir,
+			EnvVars:     []string{"DRY_RUN_DIR"},
+		},
+		&cli.BoolFlag{
+			Name:        "dry-run-publish-resource-slices",
+			Usage:       "Publish ResourceSlices in dry-run mode (they are only logged otherwise). Claims allocated on them are prepared without devices.",
+			Destination: &flags.dryRunPublishResourceSlices,
+			EnvVars:     []string{"DRY_RUN_PUBLISH_RESOURCE_SLICES"},
+		},
+		&cli.StringFlag{
+			Name:        "audit-log-path",
+			Usage:       "File to append an audit log (JSON lines) of all device mutations on this node to: MIG devices and MIG mode, compute mode, time slice, vfio driver binding and HAMi-core cache directories. Disabled if empty.",
+			Destination: &flags.auditLogPath,
+			EnvVars:     []string{"AUDIT_LOG_PATH"},
+		},
+		&cli.IntFlag{
+			Name:        "audit-log-max-size",
+			Usage:       "Size (in MiB) at which the audit log is rotated. Zero disables rotation.",
+			Value:       100,
+			Destination: &flags.auditLogMaxSizeMB,
+			EnvVars:     []string{"AUDIT_LOG_MAX_SIZE"},
+		},
+		&cli.IntFlag{
+			Name:        "audit-log-max-backups",
+			Usage:       "Number of rotated audit log files to retain.",
+			Value:       5,
+			Destination: &flags.auditLogMaxBackups,
+			EnvVars:     []string{"AUDIT_LOG_MAX_BACKUPS"},
+		},
 		// TODO: change to StringSliceFlag.
 		&cli.StringFlag{
 			Name:        "additional-xids-to-ignore",
@@ -162,6 +272,94 @@ func newApp() *cli.App {
 			Destination: &flags.additionalXidsToIgnore,
 			EnvVars:     []string{"ADDITIONAL_XIDS_TO_IGNORE"},
 		},
+		&cli.StringFlag{
+			Name:        "xid-policy-file",
+			Usage:       "Path to a YAML file with the device health policy: rules mapping XIDs (and XID ranges) to health actions (Ignore, Degrade, MarkUnhealthy, MarkUnhealthyFor, RequireReset), the default action for other XIDs, and the rules of other NVML events ('events:') and of the periodic health probes ('probes:'). Takes precedence over the built-in policy; reloaded upon change. The name predates events and probes.",
+			Destination: &flags.xidPolicyFile,
+			EnvVars:     []string{"XID_POLICY_FILE"},
+		},
+		&cli.DurationFlag{
+			Name:        "device-health-recovery-interval",
+			Usage:       "Interval at which devices marked unhealthy are re-probed for recovery. Zero disables recovery (except for timed rules of the health policy).",
+			Value:       time.Minute,
+			Destination: &flags.deviceHealthRecoveryInterval,
+			EnvVars:     []string{"DEVICE_HEALTH_RECOVERY_INTERVAL"},
+		},
+		&cli.DurationFlag{
+			Name:        "device-health-recovery-cooldown",
+			Usage:       "Time without health events after which a device marked unhealthy is re-probed and, if the probe passes, marked healthy again.",
+			Value:       5 * time.Minute,
+			Destination: &flags.deviceHealthRecoveryCooldown,
+			EnvVars:     []string{"DEVICE_HEALTH_RECOVERY_COOLDOWN"},
+		},
+		&cli.DurationFlag{
+			Name:        "device-health-probe-interval",
+			Usage:       "Interval at which GPUs are probed for failures not reported by NVML events (retired pages, remapped rows, ECC errors, PCIe replays, temperature, unresponsive GPUs). Probes are configured in the health policy file (see --xid-policy-file). Zero disables probing.",
+			Value:       time.Minute,
+			Destination: &flags.deviceHealthProbeInterval,
+			EnvVars:     []string{"DEVICE_HEALTH_PROBE_INTERVAL"},
+		},
+		&cli.StringFlag{
+			Name:        "unhealthy-claim-action",
+			Usage:       "How to flag completely prepared claims using a device marked unhealthy by a health policy rule with 'failClaims: true'. 'None': do nothing. 'ClaimCondition': set the DeviceUnhealthy condition in the device status of the ResourceClaim. 'AnnotatePods': annotate the pods consuming the claim.",
+			Value:       UnhealthyClaimActionNone,
+			Destination: &flags.unhealthyClaimAction,
+			EnvVars:     []string{"UNHEALTHY_CLAIM_ACTION"},
+		},
+		&cli.StringFlag{
+			Name:        "claim-cleanup-mode",
+			Usage:       "How to detect checkpointed claims whose ResourceClaim is gone from the API server. 'poll': periodically look up partially prepared claims. 'informer': watch ResourceClaims and also cover completely prepared claims. The informer watches the ResourceClaims of the entire cluster (the API server cannot filter them by node) and caches a minimal copy of each: its memory use and the watch traffic grow with the number of claims in the cluster.",
+			Value:       ClaimCleanupModePoll,
+			Destination: &flags.claimCleanupMode,
+			EnvVars:     []string{"CLAIM_CLEANUP_MODE"},
+		},
+		&cli.BoolFlag{
+			Name:        "claim-cleanup-dry-run",
+			Usage:       "Only log which stale checkpointed claims would be unprepared.",
+			Destination: &flags.claimCleanupDryRun,
+			EnvVars:     []string{"CLAIM_CLEANUP_DRY_RUN"},
+		},
+		&cli.DurationFlag{
+			Name:        "claim-cleanup-interval",
+			Usage:       "Interval between two runs of the stale checkpointed claim cleanup. Longer when backing off after API server errors.",
+			Value:       ResourceClaimCleanupInterval,
+			Destination: &flags.claimCleanupInterval,
+			EnvVars:     []string{"CLAIM_CLEANUP_INTERVAL"},
+		},
+		&cli.DurationFlag{
+			Name:        "claim-cleanup-start-jitter",
+			Usage:       "Upper bound for the random delay of the first stale checkpointed claim cleanup run after startup. Spreads API server load when plugins on many nodes restart at the same time.",
+			Value:       2 * time.Minute,
+			Destination: &flags.claimCleanupStartJitter,
+			EnvVars:     []string{"CLAIM_CLEANUP_START_JITTER"},
+		},
+		&cli.IntFlag{
+			Name:        "claim-cleanup-max-unprepare-attempts",
+			Usage:       "Number of failed attempts to unprepare a stale checkpointed claim after which it is reported (Event, metric) and not retried anymore. Zero means unlimited.",
+			Value:       5,
+			Destination: &flags.claimCleanupMaxUnprepareAttempts,
+			EnvVars:     []string{"CLAIM_CLEANUP_MAX_UNPREPARE_ATTEMPTS"},
+		},
+		&cli.DurationFlag{
+			Name:        "mig-reconcile-interval",
+			Usage:       "Interval for comparing live MIG devices with prepared claims (DynamicMIG only). Zero disables periodic reconciliation.",
+			Value:       10 * time.Minute,
+			Destination: &flags.migReconcileInterval,
+			EnvVars:     []string{"MIG_RECONCILE_INTERVAL"},
+		},
+		&cli.BoolFlag{
+			Name:        "mig-reconcile-teardown",
+			Usage:       "Tear down MIG devices found by the periodic reconciliation that are not referenced by any prepared claim and have no running processes.",
+			Destination: &flags.migReconcileTeardown,
+			EnvVars:     []string{"MIG_RECONCILE_TEARDOWN"},
+		},
+		&cli.DurationFlag{
+			Name:        "gpu-telemetry-interval",
+			Usage:       "Interval for sampling utilization, memory, temperature, power and processes of all GPUs and MIG devices, exported as metrics along with the claims (and pods) using the devices. Zero disables sampling. Has no effect unless metrics are served (see --metrics-port).",
+			Value:       30 * time.Second,
+			Destination: &flags.gpuTelemetryInterval,
+			EnvVars:     []string{"GPU_TELEMETRY_INTERVAL"},
+		},
 	}
 	cliFlags = append(cliFlags, flags.kubeClientConfig.Flags()...)
 	cliFlags = append(cliFlags, featureGateConfig.Flags()...)
@@ -173,8 +371,11 @@ func newApp() *cli.App {
 		ArgsUsage:       " ",
 		HideHelpCommand: true,
 		Flags:           cliFlags,
+		Commands: []*cli.Command{
+			newStatusCommand(),
+		},
 		Before: func(c *cli.Context) error {
-			if c.Args().Len() > 0 {
+			if c.Args().Len() > 0 && c.App.Command(c.Args().First()) == nil {
 				return fmt.Errorf("arguments not supported: %v", c.Args().Slice())
 			}
 			// `loggingConfig` must be applied before doing any logging
@@ -184,10 +385,21 @@ func newApp() *cli.App {
 			// later runtime inspection (it's otherwise not accessible anymore
 			// because we do not expose the raw `cliFlags`.
 			flags.klogVerbosity = int(loggingConfig.Config.Verbosity)
+
+			// Subcommands are clients of the plugin running on this node and
+			// do not take its configuration.
+			if c.Args().Present() {
+				return err
+			}
 			pkgflags.LogStartupConfig(flags, loggingConfig)
 			return err
 		},
 		Action: func(c *cli.Context) error {
+			// Not marked `Required` on the flags themselves: urfave/cli
+			// checks those before dispatching to subcommands.
+			if err := checkRequiredFlags(c, "node-name", "image-name"); err != nil {
+				return err
+			}
 			for k, v := range featuregates.ToMap() {
 				fmt.Println(k, " = ", v)
 			}
@@ -201,8 +413,9 @@ func newApp() *cli.App {
 			}
 
 			config := &Config{
-				flags:      flags,
-				clientsets: clientSets,
+				flags:          flags,
+				clientsets:     clientSets,
+				effectiveFlags: effectiveFlags(c),
 			}
 
 			return RunPlugin(c.Context, config)
@@ -211,7 +424,9 @@ func newApp() *cli.App {
 			// Runs after `Action` (regardless of success/error). In urfave cli
 			// v2, the final error reported will be from either Action, Before,
 			// or After (whichever is non-nil and last executed).
-			klog.Infof("shutdown")
+			if !c.Args().Present() {
+				klog.Infof("shutdown")
+			}
 			logs.FlushLogs()
 			return nil
 		},
@@ -228,9 +443,24 @@ func newApp() *cli.App {
 	return app
 }
 
+// checkRequiredFlags returns an error naming those of the given flags that
+// were set neither on the command line nor in the environment.
+func checkRequiredFlags(c *cli.Context, names ...string) error {
+	var missing []string
+	for _, name := range names {
+		if c.String(name) == "" {
+			missing = append(missing, fmt.Sprintf("%q", name))
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("required flags %s not set", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
 // RunPlugin initializes and runs the GPU kubelet plugin.
 func RunPlugin(ctx context.Context, config *Config) error {
-	common.StartDebugSignalHandlers()
+	common.StartDebugSignalHandlers(config.flags.debugDumpDir)
 
 	// Create the plugin directory
 	err := os.MkdirAll(config.DriverPluginPath(), 0750)
@@ -238,6 +468,12 @@ func RunPlugin(ctx context.Context, config *Config) error {
 		return err
 	}
 
+	if config.flags.dryRun {
+		if err := config.setupDryRun(); err != nil {
+			return err
+		}
+	}
+
 	// Setup nvidia-cdi-hook binary
 	if err := config.setNvidiaCDIHookPath(); err != nil {
 		return fmt.Errorf("error setting up nvidia-cdi-hook: %w", err)
@@ -260,6 +496,33 @@ func RunPlugin(ctx context.Context, config *Config) error {
 	ctx, cancel := signal.NotifyContext(ctx, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
 	defer cancel()
 
+	eventBroadcaster, eventRecorder := newEventRecorder(ctx, config)
+	defer eventBroadcaster.Shutdown()
+	config.eventRecorder = eventRecorder
+
+	config.auditLog, err = openAuditLog(config)
+	if err != nil {
+		return fmt.Errorf("error opening audit log: %w", err)
+	}
+	defer config.auditLog.Close()
+
+	metricsServer, err := startMetricsServer(config)
+	if err != nil {
+		return fmt.Errorf("error starting metrics server: %w", err)
+	}
+	defer metricsServer.Stop()
+
+	shutdownTracing, err := setupTracing(ctx, config)
+	if err != nil {
+		return fmt.Errorf("error setting up tracing: %w", err)
+	}
+	defer func() {
+		// The context is canceled at this point.
+		if err := shutdownTracing(context.Background()); err != nil {
+			klog.Errorf("unable to flush traces: %v", err)
+		}
+	}()
+
 	// Create and start the driver
 	driver, err := NewDriver(ctx, config)
 	if err != nil {
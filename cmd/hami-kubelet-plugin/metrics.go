@@ -0,0 +1,208 @@
+/*
+Copyright 2025 The HAMi Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+const metricsNamespace = "hami_dra_kubelet_plugin"
+
+// Operations and phases of claim (un)preparation, as metric labels.
+const (
+	claimOpPrepare   = "prepare"
+	claimOpUnprepare = "unprepare"
+
+	// Prep/unprep lock (node-global, file-based).
+	phaseLockAcquisition = "lock_acquisition"
+	// Device state lock (in-process).
+	phaseStateLock       = "state_lock"
+	phaseCheckpointRead  = "checkpoint_read"
+	phaseCheckpointWrite = "checkpoint_write"
+	phaseDevices         = "devices"
+	phaseMIGCreation     = "mig_creation"
+	phaseCDIWrite        = "cdi_write"
+	phaseTotal           = "total"
+)
+
+// metricsRegistry holds all metrics exported by this plugin.
+var metricsRegistry = prometheus.NewRegistry()
+
+var (
+	migDriftDevices = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "mig",
+			Name:      "drift_devices",
+			Help:      "Number of MIG devices found in drift by the last reconciliation. kind=unknown: present but not referenced by a prepared claim; kind=missing: referenced by a prepared claim but not present.",
+		},
+		[]string{"kind"},
+	)
+	migReconcileTeardowns = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "mig",
+			Name:      "reconcile_teardowns_total",
+			Help:      "Number of unknown MIG devices the reconciler attempted to tear down, by result.",
+		},
+		[]string{"result"},
+	)
+
+	claimOperationPhaseDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "claim",
+			Name:      "operation_phase_duration_seconds",
+			Help:      "Duration of the phases of claim preparation and unpreparation.",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 2, 16),
+		},
+		[]string{"operation", "phase"},
+	)
+	claimOperationErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "claim",
+			Name:      "operation_errors_total",
+			Help:      "Number of failed claim preparations and unpreparations, by cause.",
+		},
+		[]string{"operation", "cause"},
+	)
+	preparedClaims = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "claim",
+			Name:      "prepared_claims",
+			Help:      "Number of claims in the checkpoint, by checkpoint state.",
+		},
+		[]string{"state"},
+	)
+	allocatableDevices = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "devices",
+			Name:      "allocatable",
+			Help:      "Number of allocatable devices, by type and health.",
+		},
+		[]string{"type", "health"},
+	)
+
+	claimCleanupRuns = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "claim_cleanup",
+			Name:      "runs_total",
+			Help:      "Number of periodic checkpointed claim cleanup runs.",
+		},
+	)
+	claimCleanupUnprepareFailures = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "claim_cleanup",
+			Name:      "unprepare_failures_total",
+			Help:      "Number of failed attempts to unprepare a stale checkpointed claim.",
+		},
+	)
+	claimCleanupAbandonedClaims = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "claim_cleanup",
+			Name:      "abandoned_claims",
+			Help:      "Number of stale claims still in the checkpoint for which unprepare failed too often and is not retried anymore.",
+		},
+	)
+	claimCleanupRunDuration = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "claim_cleanup",
+			Name:      "run_duration_seconds",
+			Help:      "Duration of checkpointed claim cleanup runs.",
+			Buckets:   prometheus.ExponentialBuckets(0.01, 2, 14),
+		},
+	)
+	claimCleanupUnpreparedClaims = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "claim_cleanup",
+			Name:      "unprepared_claims_total",
+			Help:      "Number of stale checkpointed claims unprepared by the cleanup.",
+		},
+	)
+)
+
+func init() {
+	metricsRegistry.MustRegister(
+		migDriftDevices,
+		migReconcileTeardowns,
+		claimCleanupRuns,
+		claimCleanupUnprepareFailures,
+		claimCleanupAbandonedClaims,
+		claimCleanupRunDuration,
+		claimCleanupUnpreparedClaims,
+		claimOperationPhaseDuration,
+		claimOperationErrors,
+		preparedClaims,
+		allocatableDevices,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// observePhase records the duration of a phase of a claim operation which
+// started at t0.
+func observePhase(operation, phase string, t0 time.Time) {
+	claimOperationPhaseDuration.WithLabelValues(operation, phase).Observe(time.Since(t0).Seconds())
+}
+
+// claimOperationError counts a failed claim operation, and returns err.
+func claimOperationError(operation, cause string, err error) error {
+	claimOperationErrors.WithLabelValues(operation, cause).Inc()
+	return err
+}
+
+// updatePreparedClaimsMetric sets the prepared claims gauge from the
+// checkpoint.
+func updatePreparedClaimsMetric(cp *Checkpoint) {
+	counts := map[ClaimCheckpointState]int{
+		ClaimCheckpointStatePrepareStarted:   0,
+		ClaimCheckpointStatePrepareCompleted: 0,
+	}
+	for _, claim := range cp.V2.PreparedClaims {
+		counts[claim.CheckpointState]++
+	}
+	for state, count := range counts {
+		preparedClaims.WithLabelValues(string(state)).Set(float64(count))
+	}
+}
+
+// updateAllocatableDevicesMetric sets the allocatable devices gauge. Call it
+// with the device state lock held.
+func updateAllocatableDevicesMetric(allocatable AllocatableDevices) {
+	allocatableDevices.Reset()
+	for _, device := range allocatable {
+		health := Healthy
+		switch {
+		case !device.IsHealthy():
+			health = Unhealthy
+		case device.IsDegraded():
+			health = Degraded
+		}
+		allocatableDevices.WithLabelValues(device.Type(), string(health)).Inc()
+	}
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2025 The HAMi Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"k8s.io/klog/v2"
+)
+
+const metricsServerShutdownTimeout = 5 * time.Second
+
+type metricsServer struct {
+	server *http.Server
+}
+
+// startMetricsServer serves the metrics of metricsRegistry on /metrics.
+// Returns nil if the metrics port is negative (disabled).
+func startMetricsServer(config *Config) (*metricsServer, error) {
+	port := config.flags.metricsPort
+	if port < 0 {
+		return nil, nil
+	}
+
+	lis, err := net.Listen("tcp", net.JoinHostPort("", strconv.Itoa(port)))
+	if err != nil {
+		return nil, fmt.Errorf("error listening on metrics port %d: %w", port, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{}))
+	m := &metricsServer{
+		server: &http.Server{
+			Handler:           mux,
+			ReadHeaderTimeout: 10 * time.Second,
+		},
+	}
+
+	go func() {
+		klog.Infof("Serving metrics on %s", lis.Addr())
+		if err := m.server.Serve(lis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			klog.Errorf("Metrics server failed: %v", err)
+		}
+	}()
+	return m, nil
+}
+
+func (m *metricsServer) Stop() {
+	if m == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), metricsServerShutdownTimeout)
+	defer cancel()
+	if err := m.server.Shutdown(ctx); err != nil {
+		klog.Errorf("Unable to cleanly shutdown metrics server: %v", err)
+	}
+}
@@ -0,0 +1,186 @@
+/*
+Copyright 2025 The HAMi Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	"github.com/NVIDIA/k8s-dra-driver-gpu/pkg/flock"
+)
+
+// MigDrift describes the difference between the MIG devices present on this
+// node and the MIG devices referenced by claims in PrepareCompleted state.
+type MigDrift struct {
+	// Present, but not referenced by any completely prepared claim.
+	Unknown []DeviceName
+	// Referenced by a completely prepared claim, but not present.
+	Missing []DeviceName
+	// Subset of `Unknown` that was successfully torn down.
+	TornDown []DeviceName
+}
+
+func (d *MigDrift) Empty() bool {
+	return len(d.Unknown) == 0 && len(d.Missing) == 0
+}
+
+// ReconcileMIGDevices() is the periodic counterpart of
+// DestroyUnknownMIGDevices(): it compares the live MIG devices (GI/CI pairs)
+// with those referenced by claims in PrepareCompleted state. Unknown devices
+// are only torn down if `teardown` is set and if no process is running on
+// them -- drift found at runtime (as opposed to during startup) is more likely
+// to be caused by an administrator or by a bug in here than by an interrupted
+// transaction, and workload must not be affected.
+//
+// The checkpoint lock is held during the entire operation so that no other
+// (plugin) process can mutate the set of prepared claims in the meantime. The
+// caller is expected to hold the prep/unprep lock, too: a Prepare() in flight
+// creates MIG devices while its claim is still in PrepareStarted state.
+func (s *DeviceState) ReconcileMIGDevices(ctx context.Context, teardown bool) (*MigDrift, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	release, err := s.cplock.Acquire(ctx, flock.WithTimeout(10*time.Second))
+	if err != nil {
+		return nil, fmt.Errorf("error acquiring cplock: %w", err)
+	}
+	defer release()
+
+	checkpoint := &Checkpoint{}
+	if err := s.checkpointManager.GetCheckpoint(DriverPluginCheckpointFileBasename, checkpoint); err != nil {
+		return nil, fmt.Errorf("unable to get checkpoint: %w", err)
+	}
+	cp := checkpoint.ToLatestVersion()
+
+	expected := make(map[DeviceName]bool)
+	for _, claim := range cp.V2.PreparedClaims {
+		if claim.CheckpointState != ClaimCheckpointStatePrepareCompleted || claim.Status.Allocation == nil {
+			continue
+		}
+		for _, res := range claim.Status.Allocation.Devices.Results {
+			if res.Driver != DriverName {
+				continue
+			}
+			if _, err := NewMigSpecTupleFromCanonicalName(res.Device); err != nil {
+				// Not a MIG device.
+				continue
+			}
+			expected[res.Device] = true
+		}
+	}
+
+	live, err := s.nvdevlib.getLiveMigDevices()
+	if err != nil {
+		return nil, fmt.Errorf("error getting live MIG devices: %w", err)
+	}
+
+	drift := &MigDrift{}
+	for _, name := range slices.Sorted(maps.Keys(expected)) {
+		if _, exists := live[name]; !exists {
+			drift.Missing = append(drift.Missing, name)
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(live)) {
+		if !expected[name] {
+			drift.Unknown = append(drift.Unknown, name)
+		}
+	}
+
+	if !teardown {
+		return drift, nil
+	}
+
+	for _, name := range drift.Unknown {
+		mdi := live[name]
+		logger := klog.FromContext(ctx).WithValues(logKeyDevice, name, logKeyMIGUUID, mdi.UUID)
+		busy, err := s.nvdevlib.migDeviceHasProcesses(mdi.UUID)
+		if err != nil {
+			logger.Error(err, "MIG reconcile: skip teardown of unknown MIG device")
+			migReconcileTeardowns.WithLabelValues("error").Inc()
+			continue
+		}
+		if busy {
+			logger.Info("MIG reconcile: skip teardown of unknown MIG device: processes running")
+			migReconcileTeardowns.WithLabelValues("busy").Inc()
+			continue
+		}
+		logger.Info("MIG reconcile: tear down unknown MIG device")
+		if err := s.nvdevlib.deleteMigDevice(ctx, mdi.LiveTuple()); err != nil {
+			logger.Error(err, "MIG reconcile: could not delete unknown MIG device")
+			migReconcileTeardowns.WithLabelValues("error").Inc()
+			continue
+		}
+		migReconcileTeardowns.WithLabelValues("success").Inc()
+		drift.TornDown = append(drift.TornDown, name)
+	}
+
+	return drift, nil
+}
+
+// migReconcileLoop() periodically runs ReconcileMIGDevices() until the
+// context is canceled. The first run happens after one interval: upon startup,
+// DestroyUnknownMIGDevices() has just been executed.
+func (d *driver) migReconcileLoop(ctx context.Context, interval time.Duration, teardown bool) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	defer backgroundLoops.stop(loopMIGReconcile)
+
+	for {
+		backgroundLoops.beat(loopMIGReconcile, 2*interval+time.Minute)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d.reconcileMIGDevices(ctx, teardown)
+		}
+	}
+}
+
+func (d *driver) reconcileMIGDevices(ctx context.Context, teardown bool) {
+	release, err := d.pulock.Acquire(ctx, flock.WithTimeout(10*time.Second))
+	if err != nil {
+		klog.FromContext(ctx).Error(err, "MIG reconcile: error acquiring prep/unprep lock (retry later)")
+		return
+	}
+	defer release()
+
+	t0 := time.Now()
+	drift, err := d.state.ReconcileMIGDevices(ctx, teardown)
+	klog.V(6).Infof("t_mig_reconcile %.3f s", time.Since(t0).Seconds())
+	if err != nil {
+		klog.FromContext(ctx).Error(err, "MIG reconcile failed")
+		return
+	}
+
+	migDriftDevices.WithLabelValues("unknown").Set(float64(len(drift.Unknown) - len(drift.TornDown)))
+	migDriftDevices.WithLabelValues("missing").Set(float64(len(drift.Missing)))
+
+	if drift.Empty() {
+		klog.V(4).Infof("MIG reconcile: no drift")
+		return
+	}
+
+	msg := fmt.Sprintf("MIG device drift: unknown: %v (torn down: %v), missing: %v", drift.Unknown, drift.TornDown, drift.Missing)
+	klog.FromContext(ctx).Info("MIG reconcile: device drift", "unknownDevices", drift.Unknown, "tornDownDevices", drift.TornDown, "missingDevices", drift.Missing)
+	d.state.config.eventRecorder.Event(d.state.config.NodeRef(), corev1.EventTypeWarning, "MIGDeviceDrift", msg)
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2025 The HAMi Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// NodeConditionGPULost is True while at least one GPU of the node was
+	// lost (e.g. fell off the bus). Such a GPU is not usable until it has
+	// been reset, and this plugin has been restarted.
+	NodeConditionGPULost corev1.NodeConditionType = "HAMiGPULost"
+
+	nodeConditionPatchTimeout = 10 * time.Second
+)
+
+// setGPULostCondition sets the NodeConditionGPULost condition of this node,
+// given the UUIDs of the lost GPUs. The node is left alone if no GPU was lost
+// and the condition was never set.
+func setGPULostCondition(ctx context.Context, config *Config, lostUUIDs []string) error {
+	ctx, cancel := context.WithTimeout(ctx, nodeConditionPatchTimeout)
+	defer cancel()
+
+	nodes := config.clientsets.Core.CoreV1().Nodes()
+	node, err := nodes.Get(ctx, config.flags.nodeName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("error getting node: %w", err)
+	}
+	var current *corev1.NodeCondition
+	for i := range node.Status.Conditions {
+		if node.Status.Conditions[i].Type == NodeConditionGPULost {
+			current = &node.Status.Conditions[i]
+		}
+	}
+	if current == nil && len(lostUUIDs) == 0 {
+		return nil
+	}
+
+	now := metav1.Now()
+	condition := corev1.NodeCondition{
+		Type:               NodeConditionGPULost,
+		Status:             corev1.ConditionFalse,
+		Reason:             "NoGPULost",
+		Message:            "All GPUs are reachable",
+		LastHeartbeatTime:  now,
+		LastTransitionTime: now,
+	}
+	if len(lostUUIDs) > 0 {
+		condition.Status = corev1.ConditionTrue
+		condition.Reason = "GPULost"
+		condition.Message = fmt.Sprintf("GPU(s) lost, reset required: %s", strings.Join(lostUUIDs, ", "))
+	}
+	if current != nil && current.Status == condition.Status {
+		condition.LastTransitionTime = current.LastTransitionTime
+	}
+
+	if config.flags.dryRun {
+		klog.FromContext(ctx).Info("Dry-run: not setting node condition", "condition", condition)
+		return nil
+	}
+
+	// Conditions are merged by type: this leaves other conditions alone.
+	patch, err := json.Marshal(map[string]any{
+		"status": map[string]any{
+			"conditions": []corev1.NodeCondition{condition},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("error building node status patch: %w", err)
+	}
+
+	_, err = nodes.Patch(ctx, config.flags.nodeName, types.StrategicMergePatchType, patch, metav1.PatchOptions{}, "status")
+	if err != nil {
+		return fmt.Errorf("error patching node status: %w", err)
+	}
+	return nil
+}
@@ -35,6 +35,7 @@ static int nvmlAddressingModeAvailable() {
 import "C"
 
 import (
+	"context"
 	"fmt"
 	"maps"
 	"os"
@@ -67,12 +68,16 @@ type deviceLib struct {
 	gpuInfosByUUID    map[string]*GpuInfo
 	gpuUUIDbyMinor    map[GPUMinor]string
 	devhandleByUUID   map[string]nvml.Device
+	// Log device mutations instead of doing them.
+	dryRun bool
+	// Records device mutations; nil if not configured.
+	auditLog *auditLogger
 }
 
 type GPUMinor = int
 type PerGPUMinorAllocatableDevices map[GPUMinor]AllocatableDevices
 
-func newDeviceLib(driverRoot root) (*deviceLib, error) {
+func newDeviceLib(driverRoot root, dryRun bool, auditLog *auditLogger) (*deviceLib, error) {
 	driverLibraryPath, err := driverRoot.getDriverLibraryPath()
 	if err != nil {
 		return nil, fmt.Errorf("failed to locate driver libraries: %w", err)
@@ -97,6 +102,8 @@ func newDeviceLib(driverRoot root) (*deviceLib, error) {
 		devRoot:           driverRoot.getDevRoot(),
 		nvidiaSMIPath:     nvidiaSMIPath,
 		nvpci:             nvpci,
+		dryRun:            dryRun,
+		auditLog:          auditLog,
 		gpuInfosByUUID:    make(map[string]*GpuInfo),
 		gpuUUIDbyMinor:    make(map[GPUMinor]string),
 		devhandleByUUID:   make(map[string]nvml.Device),
@@ -264,7 +271,7 @@ func (l deviceLib) GetPerGpuAllocatableDevices(indices ...int) (PerGPUMinorAlloc
 			// Best-effort handle cache warmup: store mapping between full-GPU
 			// UUID and NVML device handle in a map. Ignore failures.
 			if _, ret := l.DeviceGetHandleByUUID(gpuInfo.UUID); ret != nvml.SUCCESS {
-				klog.Warningf("DeviceGetHandleByUUIDCached failed: %s", ret)
+				klog.InfoS("DeviceGetHandleByUUIDCached failed", logKeyGPUUUID, gpuInfo.UUID, "error", ret)
 			}
 
 			// For this full device, inspect all MIG profiles and their possible
@@ -299,12 +306,12 @@ func (l deviceLib) GetPerGpuAllocatableDevices(indices ...int) (PerGPUMinorAlloc
 
 		if featuregates.Enabled(featuregates.PassthroughSupport) {
 			// Only if no MIG devices are found, allow VFIO devices.
-			klog.Infof("PassthroughSupport enabled, and %d MIG devices found", len(migdevs))
+			klog.InfoS("PassthroughSupport enabled", logKeyDevice, gpuInfo.CanonicalName(), "migDevices", len(migdevs))
 			gpuInfo.vfioEnabled = len(migdevs) == 0
 		}
 
 		if !gpuInfo.migEnabled {
-			klog.Infof("Adding device %s to allocatable devices", gpuInfo.CanonicalName())
+			klog.InfoS("Adding device to allocatable devices", logKeyDevice, gpuInfo.CanonicalName(), logKeyGPUUUID, gpuInfo.UUID)
 			// No static MIG devices prepared for this physical GPU. Announce
 			// physical GPU to be allocatable, and terminate discovery for this
 			// phyical GPU.
@@ -315,13 +322,13 @@ func (l deviceLib) GetPerGpuAllocatableDevices(indices ...int) (PerGPUMinorAlloc
 
 		// Process statically pre-configured MIG devices.
 		for _, mdev := range migdevs {
-			klog.Infof("Adding MIG device %s to allocatable devices (parent: %s)", mdev.CanonicalName(), gpuInfo.CanonicalName())
+			klog.InfoS("Adding MIG device to allocatable devices", append(deviceLogValues(mdev), "parent", gpuInfo.CanonicalName())...)
 			thisGPUAllocatable[mdev.CanonicalName()] = mdev
 		}
 
 		// Likely unintentionally stranded capacity (misconfiguration).
 		if len(migdevs) == 0 {
-			klog.Warningf("Physical GPU %s has MIG mode enabled but no configured MIG devices", gpuInfo.CanonicalName())
+			klog.InfoS("Physical GPU has MIG mode enabled but no configured MIG devices", logKeyDevice, gpuInfo.CanonicalName(), logKeyGPUUUID, gpuInfo.UUID)
 		}
 
 		perGPUAllocatable[gpuInfo.minor] = thisGPUAllocatable
@@ -414,7 +421,7 @@ func (l deviceLib) discoverVfioDevice(gpuInfo *GpuInfo) (*AllocatableDevice, err
 // Tear down any MIG devices that are present and don't belong to completed
 // claims. This can be improved for tearing down partial state (GI without CI,
 // for example).
-func (l deviceLib) obliterateStaleMIGDevices(expectedDeviceNames []DeviceName) error {
+func (l deviceLib) obliterateStaleMIGDevices(ctx context.Context, expectedDeviceNames []DeviceName) error {
 	err := l.VisitDevices(func(i int, d nvdev.Device) error {
 		ginfo, err := l.getGpuInfo(i, d)
 		if err != nil {
@@ -430,8 +437,8 @@ func (l deviceLib) obliterateStaleMIGDevices(expectedDeviceNames []DeviceName) e
 			name := mdi.CanonicalName()
 			expected := slices.Contains(expectedDeviceNames, name)
 			if !expected {
-				klog.Warningf("Found unexpected MIG device (%s), attempt to tear down", name)
-				if err := l.deleteMigDevice(mdi.LiveTuple()); err != nil {
+				klog.FromContext(ctx).Info("Found unexpected MIG device, attempt to tear down", logKeyDevice, name, logKeyMIGUUID, mdi.UUID)
+				if err := l.deleteMigDevice(ctx, mdi.LiveTuple()); err != nil {
 					return fmt.Errorf("could not delete unexpected MIG device (%s): %w", name, err)
 				}
 			}
@@ -439,7 +446,7 @@ func (l deviceLib) obliterateStaleMIGDevices(expectedDeviceNames []DeviceName) e
 
 		// If no MIG device was found on this GPU, MIG mode might still be
 		// enabled. Disable it in this case.
-		if err := l.maybeDisableMigMode(ginfo.UUID, d); err != nil {
+		if err := l.maybeDisableMigMode(ctx, ginfo.UUID, d); err != nil {
 			return fmt.Errorf("maybeDisableMigMode failed for GPU %s: %w", ginfo.UUID, err)
 		}
 		return nil
@@ -451,6 +458,59 @@ func (l deviceLib) obliterateStaleMIGDevices(expectedDeviceNames []DeviceName) e
 	return nil
 }
 
+// getLiveMigDevices() returns the MIG devices currently present on all GPUs
+// (as seen by NVML), keyed by canonical device name.
+func (l deviceLib) getLiveMigDevices() (map[DeviceName]*MigDeviceInfo, error) {
+	live := make(map[DeviceName]*MigDeviceInfo)
+	err := l.VisitDevices(func(i int, d nvdev.Device) error {
+		ginfo, err := l.getGpuInfo(i, d)
+		if err != nil {
+			return fmt.Errorf("error getting info for GPU %d: %w", i, err)
+		}
+
+		migs, err := l.getMigDevices(ginfo)
+		if err != nil {
+			return fmt.Errorf("error getting MIG devices for GPU %d: %w", i, err)
+		}
+
+		for _, mdi := range migs {
+			live[mdi.CanonicalName()] = mdi
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error visiting devices: %w", err)
+	}
+	return live, nil
+}
+
+// migDeviceHasProcesses() returns true if at least one compute or graphics
+// process is running on the MIG device with the given UUID.
+func (l deviceLib) migDeviceHasProcesses(migUUID string) (bool, error) {
+	shutdown, ret := l.ensureNVML()
+	if ret != nvml.SUCCESS {
+		return false, fmt.Errorf("ensureNVML failed: %w", ret)
+	}
+	defer shutdown()
+
+	// Not using the handle cache: that is meant for full GPUs only.
+	dev, ret := l.nvmllib.DeviceGetHandleByUUID(migUUID)
+	if ret != nvml.SUCCESS {
+		return false, fmt.Errorf("error getting handle for MIG device %s: %v", migUUID, ret)
+	}
+
+	cprocs, ret := dev.GetComputeRunningProcesses()
+	if ret != nvml.SUCCESS {
+		return false, fmt.Errorf("error getting compute processes for MIG device %s: %v", migUUID, ret)
+	}
+	gprocs, ret := dev.GetGraphicsRunningProcesses()
+	if ret != nvml.SUCCESS && ret != nvml.ERROR_NOT_SUPPORTED {
+		return false, fmt.Errorf("error getting graphics processes for MIG device %s: %v", migUUID, ret)
+	}
+
+	return len(cprocs)+len(gprocs) > 0, nil
+}
+
 func (l deviceLib) getGpuInfo(index int, device nvdev.Device) (*GpuInfo, error) {
 	minor, ret := device.GetMinorNumber()
 	if ret != nvml.SUCCESS {
@@ -508,7 +568,7 @@ func (l deviceLib) getGpuInfo(index int, device nvdev.Device) (*GpuInfo, error)
 	var addressingMode *string
 	if C.nvmlAddressingModeAvailable() != 0 {
 		if mode, err := device.GetAddressingModeAsString(); err != nil {
-			klog.Warningf("error getting addressing mode for device %d, continuing without attribute: %v", index, err)
+			klog.ErrorS(err, "Error getting addressing mode, continuing without attribute", logKeyGPUUUID, uuid)
 		} else if mode != "" {
 			addressingMode = &mode
 		}
@@ -518,7 +578,7 @@ func (l deviceLib) getGpuInfo(index int, device nvdev.Device) (*GpuInfo, error)
 	if attr, err := deviceattribute.GetPCIeRootAttributeByPCIBusID(pcieBusID); err == nil {
 		pcieRootAttr = &attr
 	} else {
-		klog.Warningf("error getting PCIe root for device %d, continuing without attribute: %v", index, err)
+		klog.ErrorS(err, "Error getting PCIe root, continuing without attribute", logKeyGPUUUID, uuid)
 	}
 
 	var migProfiles []*MigProfileInfo
@@ -643,7 +703,7 @@ func (l deviceLib) getVfioDeviceInfo(idx int, device *nvpci.NvidiaPCIDevice) (*V
 	if err == nil {
 		pcieRootAttr = &attr
 	} else {
-		klog.Warningf("error getting PCIe root for device %s, continuing without attribute: %v", device.Address, err)
+		klog.ErrorS(err, "Error getting PCIe root, continuing without attribute", "pciBusID", device.Address)
 	}
 
 	_, memoryBytes := device.Resources.GetTotalAddressableMemory(true)
@@ -799,8 +859,12 @@ func walkMigDevices(d nvml.Device, f func(i int, d nvml.Device) error) error {
 	return nil
 }
 
-func (l deviceLib) setTimeSlice(uuids []string, timeSlice int) error {
+func (l deviceLib) setTimeSlice(ctx context.Context, uuids []string, timeSlice int) error {
 	for _, uuid := range uuids {
+		if l.dryRunMutation(ctx, auditOpSetTimeSlice, uuid, "", fmt.Sprintf("%d", timeSlice)) {
+			continue
+		}
+		t0 := time.Now()
 		cmd := exec.Command(
 			l.nvidiaSMIPath,
 			"compute-policy",
@@ -811,16 +875,22 @@ func (l deviceLib) setTimeSlice(uuids []string, timeSlice int) error {
 		cmd.Env = setOrOverrideEnvvar(os.Environ(), "LD_PRELOAD", prependPathListEnvvar("LD_PRELOAD", l.driverLibraryPath))
 
 		output, err := cmd.CombinedOutput()
+		l.auditLog.mutation(ctx, auditOpSetTimeSlice, uuid, "", fmt.Sprintf("%d", timeSlice), t0, err)
 		if err != nil {
-			klog.Errorf("\n%v", string(output))
+			klog.FromContext(ctx).Error(err, "nvidia-smi failed to set time slice", logKeyGPUUUID, uuid, "output", string(output))
 			return fmt.Errorf("error running nvidia-smi: %w", err)
 		}
 	}
 	return nil
 }
 
-func (l deviceLib) setComputeMode(uuids []string, mode string) error {
+func (l deviceLib) setComputeMode(ctx context.Context, uuids []string, mode string) error {
 	for _, uuid := range uuids {
+		t0 := time.Now()
+		before := l.computeMode(uuid)
+		if l.dryRunMutation(ctx, auditOpSetComputeMode, uuid, before, mode) {
+			continue
+		}
 		cmd := exec.Command(
 			l.nvidiaSMIPath,
 			"-i", uuid,
@@ -830,14 +900,47 @@ func (l deviceLib) setComputeMode(uuids []string, mode string) error {
 		cmd.Env = setOrOverrideEnvvar(os.Environ(), "LD_PRELOAD", prependPathListEnvvar("LD_PRELOAD", l.driverLibraryPath))
 
 		output, err := cmd.CombinedOutput()
+		l.auditLog.mutation(ctx, auditOpSetComputeMode, uuid, before, mode, t0, err)
 		if err != nil {
-			klog.Errorf("\n%v", string(output))
+			klog.FromContext(ctx).Error(err, "nvidia-smi failed to set compute mode", logKeyGPUUUID, uuid, "output", string(output))
 			return fmt.Errorf("error running nvidia-smi: %w", err)
 		}
 	}
 	return nil
 }
 
+// computeMode returns the current compute mode of a GPU, named as in
+// `nvidia-smi -c` (empty if unknown).
+func (l deviceLib) computeMode(uuid string) string {
+	device, ret := l.DeviceGetHandleByUUID(uuid)
+	if ret != nvml.SUCCESS {
+		return ""
+	}
+	mode, ret := device.GetComputeMode()
+	if ret != nvml.SUCCESS {
+		return ""
+	}
+	switch mode {
+	case nvml.COMPUTEMODE_DEFAULT:
+		return "DEFAULT"
+	case nvml.COMPUTEMODE_EXCLUSIVE_THREAD:
+		return "EXCLUSIVE_THREAD"
+	case nvml.COMPUTEMODE_PROHIBITED:
+		return "PROHIBITED"
+	case nvml.COMPUTEMODE_EXCLUSIVE_PROCESS:
+		return "EXCLUSIVE_PROCESS"
+	}
+	return ""
+}
+
+// nvmlError returns ret as error, or nil upon success.
+func nvmlError(ret nvml.Return) error {
+	if ret == nvml.SUCCESS {
+		return nil
+	}
+	return ret
+}
+
 // Get an NVML device handle for a physical GPU. When not in DynamicMIG mode,
 // this currently always calls out to NVML's DeviceGetHandleByUUID(). In
 // DynamicMIG mode, this function maintains an NVML handle cache and hence
@@ -862,7 +965,7 @@ func (l deviceLib) DeviceGetHandleByUUID(uuid string) (nvml.Device, nvml.Return)
 		return dev, nvml.SUCCESS
 	}
 
-	klog.V(6).Infof("DeviceGetHandleByUUID called for %s, cache miss", uuid)
+	klog.V(6).InfoS("DeviceGetHandleByUUID cache miss", logKeyGPUUUID, uuid)
 	// Note(JP): This call can be slow. Hence, the decision to use long-lived
 	// handles (at least for DynamicMIG). In theory here we need a request
 	// coalescing strategy (otherwise, cache stampede is a thing in practice: a
@@ -887,7 +990,19 @@ func (l deviceLib) DeviceGetHandleByUUID(uuid string) (nvml.Device, nvml.Return)
 }
 
 // Assume long-lived NVML session.
-func (l deviceLib) createMigDevice(migspec *MigSpec) (*MigDeviceInfo, error) {
+func (l deviceLib) createMigDevice(ctx context.Context, migspec *MigSpec) (migDevInfo *MigDeviceInfo, rerr error) {
+	if l.dryRunMutation(ctx, auditOpCreateMigDevice, migspec.Parent.UUID, "", string(migspec.CanonicalName())) {
+		return dryRunMigDeviceInfo(migspec), nil
+	}
+	t0 := time.Now()
+	defer func() {
+		var after string
+		if migDevInfo != nil {
+			after = fmt.Sprintf("%s (%s)", migDevInfo.CanonicalName(), migDevInfo.UUID)
+		}
+		l.auditLog.mutation(ctx, auditOpCreateMigDevice, migspec.Parent.UUID, "", after, t0, rerr)
+	}()
+
 	gpu := migspec.Parent
 	profile := migspec.Profile
 	placement := &migspec.Placement
@@ -918,21 +1033,22 @@ func (l deviceLib) createMigDevice(migspec *MigSpec) (*MigDeviceInfo, error) {
 	}
 	klog.V(7).Infof("t_prep_create_mig_dev_check_mig_enabled %.3f s", time.Since(tcme0).Seconds())
 
-	logpfx := fmt.Sprintf("Create %s", migspec.CanonicalName())
+	logger := klog.FromContext(ctx).WithValues(logKeyDevice, migspec.CanonicalName(), logKeyGPUUUID, gpu.UUID)
 
 	if !migEnabled {
-		klog.V(6).Infof("%s: Attempting to enable MIG mode for to-be parent %s", logpfx, gpu.String())
+		logger.V(6).Info("Create MIG device: attempting to enable MIG mode for to-be parent")
 		// If this is newer than A100 and if device unused: enable MIG.
 		tem0 := time.Now()
 		ret, activationStatus := device.SetMigMode(nvml.DEVICE_MIG_ENABLE)
+		l.auditLog.mutation(ctx, auditOpSetMigMode, gpu.UUID, "Disabled", "Enabled", tem0, nvmlError(ret))
 		if ret != nvml.SUCCESS {
 			// activationStatus would return the appropriate error code upon unsuccessful activation
-			klog.Warningf("%s: SetMigMode activationStatus (device %s): %s", logpfx, gpu.String(), activationStatus)
+			logger.Info("Create MIG device: SetMigMode failed", "activationStatus", activationStatus)
 			return nil, fmt.Errorf("error enabling MIG mode for device %s: %v", gpu.String(), ret)
 		}
-		klog.V(1).Infof("%s: MIG mode now enabled for device %s, t_enable_mig %.3f s", logpfx, gpu.String(), time.Since(tem0).Seconds())
+		logger.V(1).Info("Create MIG device: MIG mode now enabled", logKeyDuration, time.Since(tem0).Seconds())
 	} else {
-		klog.V(6).Infof("%s: MIG mode already enabled for device %s", logpfx, gpu.String())
+		logger.V(6).Info("Create MIG device: MIG mode already enabled")
 	}
 
 	profileInfo := profile.GetInfo()
@@ -997,7 +1113,7 @@ func (l deviceLib) createMigDevice(migspec *MigSpec) (*MigDeviceInfo, error) {
 
 	// This now probably needs consolidation with the new types MigLiveTuple and
 	// MigSpecTuple. Things get confusing.
-	migDevInfo := &MigDeviceInfo{
+	migDevInfo = &MigDeviceInfo{
 		UUID:           uuid,
 		CIID:           int(ciInfo.Id),
 		GIID:           int(giInfo.Id),
@@ -1012,19 +1128,27 @@ func (l deviceLib) createMigDevice(migspec *MigSpec) (*MigDeviceInfo, error) {
 		parent:         gpu,
 	}
 
-	klog.V(6).Infof("%s: MIG device created on %s: %+v", logpfx, gpu.String(), migDevInfo.LiveTuple())
+	logger.V(6).Info("MIG device created", logKeyMIGUUID, migDevInfo.UUID, "giID", migDevInfo.GIID, "ciID", migDevInfo.CIID)
 	return migDevInfo, nil
 }
 
 // Assume long-lived NVML session.
-func (l deviceLib) deleteMigDevice(miglt *MigLiveTuple) error {
+func (l deviceLib) deleteMigDevice(ctx context.Context, miglt *MigLiveTuple) (rerr error) {
 	parentUUID := miglt.ParentUUID
 	giId := miglt.GIID
 	ciId := miglt.CIID
 
+	if l.dryRunMutation(ctx, auditOpDeleteMigDevice, parentUUID, fmt.Sprintf("%s (GI: %d, CI: %d)", miglt.MigUUID, giId, ciId), "") {
+		return nil
+	}
 	t0 := time.Now()
+	defer func() {
+		before := fmt.Sprintf("%s (GI: %d, CI: %d)", miglt.MigUUID, giId, ciId)
+		l.auditLog.mutation(ctx, auditOpDeleteMigDevice, parentUUID, before, "", t0, rerr)
+	}()
 	migStr := fmt.Sprintf("MIG(parent: %s, %+v)", parentUUID, miglt)
-	klog.V(6).Infof("Delete %s", migStr)
+	logger := klog.FromContext(ctx).WithValues(logKeyMIGUUID, miglt.MigUUID, logKeyGPUUUID, parentUUID, "giID", giId, "ciID", ciId)
+	logger.V(6).Info("Delete MIG device")
 
 	parentNvmlDev, ret := l.DeviceGetHandleByUUID(parentUUID)
 	if ret != nvml.SUCCESS {
@@ -1041,7 +1165,7 @@ func (l deviceLib) deleteMigDevice(miglt *MigLiveTuple) error {
 	// enabled" -- for the unlikely case that we end up in this state (MIG mode
 	// was disabled out-of-band?), this should be treated as deletion success.
 	if gires == nvml.ERROR_NOT_SUPPORTED {
-		klog.Infof("Delete %s: GetGpuInstanceById yielded ERROR_NOT_SUPPORTED: MIG disabled, treat as success", migStr)
+		logger.Info("Delete MIG device: GetGpuInstanceById yielded ERROR_NOT_SUPPORTED: MIG disabled, treat as success")
 		return nil
 	}
 
@@ -1053,8 +1177,8 @@ func (l deviceLib) deleteMigDevice(miglt *MigLiveTuple) error {
 	if gires == nvml.ERROR_NOT_FOUND {
 		// In this case assume that no compute instances exist (as of the GI>CI
 		// hierarchy) and proceed with attempt-to-disable-MIG-mode
-		klog.Infof("Delete %s: GI was not found skip CI cleanup", migStr)
-		if err := l.maybeDisableMigMode(parentUUID, parentNvmlDev); err != nil {
+		logger.Info("Delete MIG device: GI was not found, skip CI cleanup")
+		if err := l.maybeDisableMigMode(ctx, parentUUID, parentNvmlDev); err != nil {
 			return fmt.Errorf("failed maybeDisableMigMode: %w", err)
 		}
 		return nil
@@ -1088,7 +1212,7 @@ func (l deviceLib) deleteMigDevice(miglt *MigLiveTuple) error {
 	// A previous, partial cleanup may actually have already deleted that. Seen
 	// in practice. Ignore, and proceed with deleting GPU instance below.
 	if cires == nvml.ERROR_NOT_FOUND {
-		klog.Infof("Delete %s: CI not found, ignore", migStr)
+		logger.Info("Delete MIG device: CI not found, ignore")
 	} else {
 		ret := ci.Destroy()
 		if ret != nvml.SUCCESS {
@@ -1108,16 +1232,16 @@ func (l deviceLib) deleteMigDevice(miglt *MigLiveTuple) error {
 	if ret != nvml.SUCCESS {
 		return fmt.Errorf("error destroying GPU Instance: %v", ret)
 	}
-	klog.V(6).Infof("t_delete_mig_device %.3f s", time.Since(t0).Seconds())
+	logger.V(6).Info("Delete MIG device: GI destroyed", logKeyDuration, time.Since(t0).Seconds())
 
-	if err := l.maybeDisableMigMode(parentUUID, parentNvmlDev); err != nil {
+	if err := l.maybeDisableMigMode(ctx, parentUUID, parentNvmlDev); err != nil {
 		return fmt.Errorf("failed maybeDisableMigMode: %w", err)
 	}
 
 	return nil
 }
 
-func (l deviceLib) maybeDisableMigMode(uuid string, nvmldev nvml.Device) error {
+func (l deviceLib) maybeDisableMigMode(ctx context.Context, uuid string, nvmldev nvml.Device) error {
 	// Expect the parent GPU to be represented in in `l.gpuInfosByUUID`
 	gpu, ok := l.gpuInfosByUUID[uuid]
 	if !ok {
@@ -1130,18 +1254,23 @@ func (l deviceLib) maybeDisableMigMode(uuid string, nvmldev nvml.Device) error {
 		return fmt.Errorf("error getting MIG devices for %s: %w", gpu.String(), err)
 	}
 
+	logger := klog.FromContext(ctx).WithValues(logKeyDevice, gpu.CanonicalName(), logKeyGPUUUID, uuid)
 	if len(migs) > 0 {
-		klog.V(6).Infof("Leaving MIG mode enabled for device %s (currently present MIG devices: %d)", gpu.String(), len(migs))
+		logger.V(6).Info("Leaving MIG mode enabled", "migDevices", len(migs))
 		return nil
 	}
 
-	klog.V(6).Infof("Attempting to disable MIG mode for device %s", gpu.String())
+	if l.dryRunMutation(ctx, auditOpSetMigMode, uuid, "", "Disabled") {
+		return nil
+	}
+	logger.V(6).Info("Attempting to disable MIG mode")
 	t0 := time.Now()
 	ret, activationStatus := nvmldev.SetMigMode(nvml.DEVICE_MIG_DISABLE)
-	klog.V(6).Infof("t_disable_mig %.3f s", time.Since(t0).Seconds())
+	l.auditLog.mutation(ctx, auditOpSetMigMode, uuid, "Enabled", "Disabled", t0, nvmlError(ret))
+	logPhase(logger, 6, "disable_mig", t0)
 	if ret != nvml.SUCCESS {
 		// activationStatus would return the appropriate error code upon unsuccessful activation
-		klog.Warningf("SetMigMode activationStatus (device %s): %s", gpu.String(), activationStatus)
+		logger.Info("SetMigMode failed", "activationStatus", activationStatus)
 		// We could also log this as an error and proceed, and hope for the
 		// state machine to clean this up in the future. Probably not a good
 		// idea.
@@ -1149,7 +1278,7 @@ func (l deviceLib) maybeDisableMigMode(uuid string, nvmldev nvml.Device) error {
 	}
 	// Note: when we're here, disabling MIG mode might still have failed.
 	// `activationStatus` may reflect "in use by another client".
-	klog.V(1).Infof("Called nvml.SetMigMode(nvml.DEVICE_MIG_DISABLE) for device %s, got activationStatus: %s", gpu.String(), activationStatus)
+	logger.V(1).Info("Called nvml.SetMigMode(nvml.DEVICE_MIG_DISABLE)", "activationStatus", activationStatus)
 	return nil
 }
 
@@ -1218,8 +1347,8 @@ func (l deviceLib) inspectMigProfilesAndPlacements(gpuInfo *GpuInfo, device nvde
 		return nil
 	})
 
-	klog.V(1).Infof("%s: Per-capacity maximum across all MIG profiles+placements: %v", gpuInfo.String(), maxCapacities)
-	klog.V(1).Infof("%s: Largest MIG placement size seen (maxMemSlicesConsumed): %d", gpuInfo.String(), maxMemSlicesConsumed)
+	klog.V(1).InfoS("Per-capacity maximum across all MIG profiles+placements", logKeyDevice, gpuInfo.CanonicalName(), logKeyGPUUUID, gpuInfo.UUID, "maxCapacities", maxCapacities)
+	klog.V(1).InfoS("Largest MIG placement size seen", logKeyDevice, gpuInfo.CanonicalName(), logKeyGPUUUID, gpuInfo.UUID, "maxMemSlicesConsumed", maxMemSlicesConsumed)
 
 	if err != nil {
 		return nil, fmt.Errorf("error visiting MIG profiles: %w", err)
@@ -1250,7 +1379,7 @@ func (l deviceLib) FindMigDevBySpec(ms *MigSpecTuple) (*MigLiveTuple, error) {
 	for i := range count {
 		migHandle, ret := parent.GetMigDeviceHandleByIndex(i)
 		if ret != nvml.SUCCESS {
-			klog.Infof("GetMigDeviceHandleByIndex ret not success")
+			klog.V(6).InfoS("GetMigDeviceHandleByIndex failed", logKeyGPUUUID, parentUUID, "index", i, "error", ret)
 			// Slot empty or invalid: treat as device does not currently exist.
 			continue
 		}
@@ -1270,19 +1399,19 @@ func (l deviceLib) FindMigDevBySpec(ms *MigSpecTuple) (*MigLiveTuple, error) {
 			return nil, fmt.Errorf("failed to get GI info: %v", ret)
 		}
 
-		klog.V(7).Infof("FindMigDevBySpec: saw MIG dev with profile id %d and placement start %d", giInfo.ProfileId, giInfo.Placement.Start)
+		klog.V(7).InfoS("FindMigDevBySpec: saw MIG device", logKeyGPUUUID, parentUUID, "profileID", giInfo.ProfileId, "placementStart", giInfo.Placement.Start)
 
 		if int(giInfo.ProfileId) != ms.ProfileID {
-			klog.V(7).Infof("profile ID mismatch: looking for %d", ms.ProfileID)
+			klog.V(7).InfoS("FindMigDevBySpec: profile ID mismatch", "wantProfileID", ms.ProfileID)
 			continue
 		}
 
 		if int(giInfo.Placement.Start) != ms.PlacementStart {
-			klog.V(7).Infof("placement start mismatch: looking for %d", ms.PlacementStart)
+			klog.V(7).InfoS("FindMigDevBySpec: placement start mismatch", "wantPlacementStart", ms.PlacementStart)
 			continue
 		}
 
-		klog.V(4).Infof("FindMigDevBySpec: match found for profile ID %d and placement start %d", giInfo.ProfileId, giInfo.Placement.Start)
+		klog.V(4).InfoS("FindMigDevBySpec: match found", logKeyGPUUUID, parentUUID, "profileID", giInfo.ProfileId, "placementStart", giInfo.Placement.Start)
 
 		// In our way of managing MIG devices, this should always be zero --
 		// nevertheless, perform the lookup. If the lookup fails, the MIG device
@@ -1291,13 +1420,13 @@ func (l deviceLib) FindMigDevBySpec(ms *MigSpecTuple) (*MigLiveTuple, error) {
 		ciId := 0
 		ciId, ret = migHandle.GetComputeInstanceId()
 		if ret != nvml.SUCCESS {
-			klog.V(4).Infof("FindMigDevBySpec(): failed to get CI ID: %v", ret)
+			klog.V(4).InfoS("FindMigDevBySpec: failed to get CI ID", logKeyGPUUUID, parentUUID, "error", ret)
 		}
 
 		uuid := ""
 		uuid, ret = migHandle.GetUUID()
 		if ret != nvml.SUCCESS {
-			klog.V(4).Infof("FindMigDevBySpec(): failed to get MIG UUID: %v", ret)
+			klog.V(4).InfoS("FindMigDevBySpec: failed to get MIG UUID", logKeyGPUUUID, parentUUID, "error", ret)
 		}
 
 		// Found device matching the spec, return handle. For subsequent
@@ -1311,11 +1440,11 @@ func (l deviceLib) FindMigDevBySpec(ms *MigSpecTuple) (*MigLiveTuple, error) {
 			MigUUID:     uuid,
 		}
 
-		klog.Infof("FindMigDevBySpec result: %+v", mlt)
+		klog.InfoS("FindMigDevBySpec: found", logKeyMIGUUID, mlt.MigUUID, logKeyGPUUUID, parentUUID, "giID", mlt.GIID, "ciID", mlt.CIID)
 		return &mlt, nil
 	}
 
-	klog.Infof("Iterated through all potential MIG devs -- no candidate found")
+	klog.InfoS("FindMigDevBySpec: no candidate found", logKeyGPUUUID, parentUUID)
 	return nil, nil
 }
 
@@ -19,6 +19,7 @@ package main
 
 import (
 	"fmt"
+	"slices"
 
 	"github.com/Masterminds/semver"
 	resourceapi "k8s.io/api/resource/v1"
@@ -115,6 +116,8 @@ func (d *GpuInfo) PartGetDevice() resourceapi.Device {
 		dev.Attributes[d.pcieRootAttr.Name] = d.pcieRootAttr.Value
 	}
 
+	addHealthAttributes(dev.Attributes, d.health, d.degraded, d.healthHistory)
+	dev.Taints = slices.Clone(d.healthTaints)
 	return dev
 }
 
@@ -125,7 +128,10 @@ func (i *MigSpec) PartGetDevice() resourceapi.Device {
 		Attributes:       i.PartAttributes(),
 		Capacity:         i.PartCapacities(),
 		ConsumesCounters: i.PartConsumesCounters(),
+		// Health is tracked for the parent.
+		Taints: slices.Clone(i.Parent.healthTaints),
 	}
+	addHealthAttributes(d.Attributes, i.Parent.health, i.Parent.degraded, i.Parent.healthHistory)
 	return d
 }
 
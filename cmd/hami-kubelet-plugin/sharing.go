@@ -105,15 +105,15 @@ func NewTimeSlicingManager(deviceLib *deviceLib) *TimeSlicingManager {
 }
 
 // `uuids` must be full-GPU (non-MIG) UUIDs. The caller must ensure that.
-func (t *TimeSlicingManager) SetTimeSlice(uuids []string, config *configapi.TimeSlicingConfig) error {
+func (t *TimeSlicingManager) SetTimeSlice(ctx context.Context, uuids []string, config *configapi.TimeSlicingConfig) error {
 	// Set the compute mode of the GPU to DEFAULT.
-	err := t.nvdevlib.setComputeMode(uuids, "DEFAULT")
+	err := t.nvdevlib.setComputeMode(ctx, uuids, "DEFAULT")
 	if err != nil {
 		return fmt.Errorf("error setting compute mode: %w", err)
 	}
 
 	// Set the time slice based on the config provided.
-	err = t.nvdevlib.setTimeSlice(uuids, config.Interval.Int())
+	err = t.nvdevlib.setTimeSlice(ctx, uuids, config.Interval.Int())
 	if err != nil {
 		return fmt.Errorf("error setting time slice: %w", err)
 	}
@@ -134,8 +134,13 @@ func NewMpsManager(config *Config, deviceLib *deviceLib, hostDriverRoot, templat
 }
 
 func (m *MpsManager) NewMpsControlDaemon(claimUID string, devices UUIDProvider) *MpsControlDaemon {
-	id := m.GetMpsControlDaemonID(claimUID, devices)
+	return m.newMpsControlDaemonWithID(m.GetMpsControlDaemonID(claimUID, devices), devices)
+}
 
+// newMpsControlDaemonWithID() allows for re-constructing the handle of a
+// previously started control daemon from its checkpointed ID. `devices` may be
+// nil when only the paths derived from the ID are of interest.
+func (m *MpsManager) newMpsControlDaemonWithID(id string, devices UUIDProvider) *MpsControlDaemon {
 	return &MpsControlDaemon{
 		id:        id,
 		nodeName:  m.config.flags.nodeName,
@@ -194,7 +199,7 @@ func (m *MpsControlDaemon) Start(ctx context.Context, config *configapi.MpsConfi
 		return nil
 	}
 
-	klog.Infof("Starting MPS control daemon for '%v', with settings: %+v", m.id, config)
+	klog.FromContext(ctx).Info("Starting MPS control daemon", "mpsControlDaemon", m.id, "settings", config)
 
 	deviceUUIDs := m.devices.UUIDs()
 
@@ -225,13 +230,17 @@ func (m *MpsControlDaemon) Start(ctx context.Context, config *configapi.MpsConfi
 		templateData.DefaultPinnedDeviceMemoryLimits = limits
 	}
 
+	_, tspan := startSpan(ctx, "RenderMpsControlDaemonTemplate")
 	tmpl, err := template.ParseFiles(m.manager.templatePath)
 	if err != nil {
+		endSpan(tspan, err)
 		return fmt.Errorf("failed to parse template file: %w", err)
 	}
 
 	var deploymentYaml bytes.Buffer
-	if err := tmpl.Execute(&deploymentYaml, templateData); err != nil {
+	err = tmpl.Execute(&deploymentYaml, templateData)
+	endSpan(tspan, err)
+	if err != nil {
 		return fmt.Errorf("failed to execute template: %w", err)
 	}
 
@@ -247,6 +256,11 @@ func (m *MpsControlDaemon) Start(ctx context.Context, config *configapi.MpsConfi
 		return fmt.Errorf("failed to convert unstructured data to typed object: %w", err)
 	}
 
+	if m.manager.config.flags.dryRun {
+		klog.FromContext(ctx).Info("Dry-run: not starting MPS control daemon (mounting its shm directory, setting compute mode and creating its Deployment)", "deployment", klog.KObj(&deployment), "gpuUUIDs", m.devices.GpuUUIDs())
+		return nil
+	}
+
 	err = os.MkdirAll(m.shmDir, 0755)
 	if err != nil {
 		return fmt.Errorf("error creating directory %v: %w", m.shmDir, err)
@@ -275,12 +289,14 @@ func (m *MpsControlDaemon) Start(ctx context.Context, config *configapi.MpsConfi
 		return fmt.Errorf("error mounting %v as tmpfs: %w", m.shmDir, err)
 	}
 
-	err = m.manager.nvdevlib.setComputeMode(m.devices.GpuUUIDs(), "EXCLUSIVE_PROCESS")
+	err = m.manager.nvdevlib.setComputeMode(ctx, m.devices.GpuUUIDs(), "EXCLUSIVE_PROCESS")
 	if err != nil {
 		return fmt.Errorf("error setting compute mode: %w", err)
 	}
 
-	_, err = m.manager.config.clientsets.Core.AppsV1().Deployments(m.namespace).Create(ctx, &deployment, metav1.CreateOptions{})
+	actx, aspan := startSpan(ctx, "CreateMpsControlDaemonDeployment")
+	_, err = m.manager.config.clientsets.Core.AppsV1().Deployments(m.namespace).Create(actx, &deployment, metav1.CreateOptions{})
+	endSpan(aspan, err)
 	if errors.IsAlreadyExists(err) {
 		return nil
 	}
@@ -292,6 +308,9 @@ func (m *MpsControlDaemon) Start(ctx context.Context, config *configapi.MpsConfi
 }
 
 func (m *MpsControlDaemon) AssertReady(ctx context.Context) error {
+	if m.manager.config.flags.dryRun {
+		return nil
+	}
 	backoff := wait.Backoff{
 		Duration: time.Second,
 		Factor:   2,
@@ -376,7 +395,7 @@ func (m *MpsControlDaemon) Stop(ctx context.Context) error {
 		return nil
 	}
 
-	klog.Infof("Stopping MPS control daemon for '%v'", m.id)
+	klog.FromContext(ctx).Info("Stopping MPS control daemon", "mpsControlDaemon", m.id)
 
 	deletePolicy := metav1.DeletePropagationForeground
 	deleteOptions := metav1.DeleteOptions{
@@ -0,0 +1,376 @@
+/*
+Copyright 2025 The HAMi Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	"k8s.io/dynamic-resource-allocation/kubeletplugin"
+	"k8s.io/klog/v2"
+
+	"github.com/Project-HAMi/k8s-dra-driver/pkg/featuregates"
+)
+
+// A read-only status API, served via HTTP on a Unix socket in the plugin
+// directory, for debugging a node: `hami-kubelet-plugin status` queries it.
+
+const (
+	StatusSocketFileBasename = "status.sock"
+
+	statusServerShutdownTimeout = 5 * time.Second
+	statusRequestTimeout        = 30 * time.Second
+)
+
+// Sections of the status, each served on /status/<section>; /status serves
+// all of them.
+const (
+	statusSectionDevices      = "devices"
+	statusSectionLayout       = "layout"
+	statusSectionClaims       = "claims"
+	statusSectionMPS          = "mps"
+	statusSectionVfio         = "vfio"
+	statusSectionFeatureGates = "featuregates"
+	statusSectionFlags        = "flags"
+)
+
+type DeviceStatus struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	UUID     string `json:"uuid,omitempty"`
+	Healthy  bool   `json:"healthy"`
+	Degraded bool   `json:"degraded"`
+	// Latest health transition (if any).
+	LastHealthRecord *HealthRecord `json:"lastHealthRecord,omitempty"`
+}
+
+type ClaimStatus struct {
+	UID       string               `json:"uid"`
+	Namespace string               `json:"namespace"`
+	Name      string               `json:"name"`
+	State     ClaimCheckpointState `json:"state"`
+	Devices   []string             `json:"devices"`
+}
+
+type MPSControlDaemonStatus struct {
+	ID       string   `json:"id"`
+	ClaimUID string   `json:"claimUID"`
+	Devices  []string `json:"devices"`
+}
+
+type VfioDeviceStatus struct {
+	Name       string `json:"name"`
+	PCIBusID   string `json:"pciBusID"`
+	Driver     string `json:"driver,omitempty"`
+	DriverErr  string `json:"driverError,omitempty"`
+	ClaimUID   string `json:"claimUID,omitempty"`
+	ClaimState string `json:"claimState,omitempty"`
+}
+
+type statusServer struct {
+	server *http.Server
+	socket string
+}
+
+// startStatusServer serves the status of the device state on the status
+// socket.
+func startStatusServer(config *Config, state *DeviceState) (*statusServer, error) {
+	socket := filepath.Join(config.DriverPluginPath(), StatusSocketFileBasename)
+	// A stale socket from a previous run.
+	if err := os.Remove(socket); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("error removing stale status socket: %w", err)
+	}
+	lis, err := net.Listen("unix", socket)
+	if err != nil {
+		return nil, fmt.Errorf("error listening on status socket: %w", err)
+	}
+	if err := os.Chmod(socket, 0600); err != nil {
+		_ = lis.Close()
+		return nil, fmt.Errorf("error restricting access to status socket: %w", err)
+	}
+
+	sections := map[string]func(context.Context) (any, error){
+		statusSectionDevices:      state.devicesStatus,
+		statusSectionLayout:       state.layoutStatus,
+		statusSectionClaims:       state.claimsStatus,
+		statusSectionMPS:          state.mpsStatus,
+		statusSectionVfio:         state.vfioStatus,
+		statusSectionFeatureGates: func(context.Context) (any, error) { return featuregates.ToMap(), nil },
+		statusSectionFlags:        func(context.Context) (any, error) { return config.effectiveFlags, nil },
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /status", func(w http.ResponseWriter, r *http.Request) {
+		all := make(map[string]any)
+		for name, section := range sections {
+			value, err := section(r.Context())
+			if err != nil {
+				http.Error(w, fmt.Sprintf("%s: %v", name, err), http.StatusInternalServerError)
+				return
+			}
+			all[name] = value
+		}
+		writeStatus(w, all)
+	})
+	mux.HandleFunc("GET /status/{section}", func(w http.ResponseWriter, r *http.Request) {
+		section, exists := sections[r.PathValue("section")]
+		if !exists {
+			http.Error(w, fmt.Sprintf("unknown section; known sections: %v", slices.Sorted(maps.Keys(sections))), http.StatusNotFound)
+			return
+		}
+		value, err := section(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeStatus(w, value)
+	})
+
+	s := &statusServer{
+		server: &http.Server{
+			Handler:           mux,
+			ReadHeaderTimeout: 10 * time.Second,
+		},
+		socket: socket,
+	}
+	go func() {
+		klog.Infof("Serving status on %s", socket)
+		if err := s.server.Serve(lis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			klog.Errorf("Status server failed: %v", err)
+		}
+	}()
+	return s, nil
+}
+
+func (s *statusServer) Stop() {
+	if s == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), statusServerShutdownTimeout)
+	defer cancel()
+	if err := s.server.Shutdown(ctx); err != nil {
+		klog.Errorf("Unable to cleanly shutdown status server: %v", err)
+	}
+}
+
+func writeStatus(w http.ResponseWriter, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		klog.Errorf("Error writing status response: %v", err)
+	}
+}
+
+func (s *DeviceState) devicesStatus(ctx context.Context) (any, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	var devices []DeviceStatus
+	for _, name := range slices.Sorted(maps.Keys(s.allocatable)) {
+		device := s.allocatable[name]
+		status := DeviceStatus{
+			Name:     name,
+			Type:     device.Type(),
+			Healthy:  device.IsHealthy(),
+			Degraded: device.IsDegraded(),
+		}
+		if device.Type() != MigDynamicDeviceType {
+			status.UUID = device.UUID()
+		}
+		if history := device.healthHistory(); history != nil && len(*history) > 0 {
+			status.LastHealthRecord = &(*history)[len(*history)-1]
+		}
+		devices = append(devices, status)
+	}
+	return devices, nil
+}
+
+// layoutStatus returns the names of the allocatable devices by GPU minor.
+func (s *DeviceState) layoutStatus(ctx context.Context) (any, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	layout := make(map[GPUMinor][]string)
+	for minor, devices := range s.perGPUAllocatable {
+		layout[minor] = slices.Sorted(maps.Keys(devices))
+	}
+	return layout, nil
+}
+
+func (s *DeviceState) claimsStatus(ctx context.Context) (any, error) {
+	cp, err := s.getCheckpoint(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get checkpoint: %w", err)
+	}
+
+	var claims []ClaimStatus
+	for _, uid := range slices.Sorted(maps.Keys(cp.V2.PreparedClaims)) {
+		claim := cp.V2.PreparedClaims[uid]
+		claims = append(claims, ClaimStatus{
+			UID:       uid,
+			Namespace: claim.Namespace,
+			Name:      claim.Name,
+			State:     claim.CheckpointState,
+			Devices:   claim.PreparedDevices.GetDeviceNames(),
+		})
+	}
+	return claims, nil
+}
+
+func (s *DeviceState) mpsStatus(ctx context.Context) (any, error) {
+	cp, err := s.getCheckpoint(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get checkpoint: %w", err)
+	}
+
+	var daemons []MPSControlDaemonStatus
+	for _, uid := range slices.Sorted(maps.Keys(cp.V2.PreparedClaims)) {
+		for _, group := range cp.V2.PreparedClaims[uid].PreparedDevices {
+			if group.ConfigState.MpsControlDaemonID == "" {
+				continue
+			}
+			daemons = append(daemons, MPSControlDaemonStatus{
+				ID:       group.ConfigState.MpsControlDaemonID,
+				ClaimUID: uid,
+				Devices:  group.UUIDs(),
+			})
+		}
+	}
+	return daemons, nil
+}
+
+// vfioStatus returns the passthrough devices, the driver they are currently
+// bound to, and the claim using them (if any).
+func (s *DeviceState) vfioStatus(ctx context.Context) (any, error) {
+	cp, err := s.getCheckpoint(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get checkpoint: %w", err)
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	var devices []VfioDeviceStatus
+	for _, name := range slices.Sorted(maps.Keys(s.allocatable)) {
+		device := s.allocatable[name]
+		if device.Type() != VfioDeviceType {
+			continue
+		}
+		status := VfioDeviceStatus{
+			Name:     name,
+			PCIBusID: device.Vfio.pcieBusID,
+		}
+		if driver, err := getDriver(pciDevicesRoot, device.Vfio.pcieBusID); err != nil {
+			status.DriverErr = err.Error()
+		} else {
+			status.Driver = driver
+		}
+		for uid, claim := range cp.V2.PreparedClaims {
+			if slices.Contains(claim.PreparedDevices.GetDeviceNames(), name) {
+				status.ClaimUID = uid
+				status.ClaimState = string(claim.CheckpointState)
+			}
+		}
+		devices = append(devices, status)
+	}
+	return devices, nil
+}
+
+// effectiveFlags returns the values of all flags, after parsing the command
+// line and environment.
+func effectiveFlags(c *cli.Context) map[string]string {
+	values := make(map[string]string)
+	for _, f := range c.App.Flags {
+		name := f.Names()[0]
+		values[name] = fmt.Sprint(c.Value(name))
+	}
+	return values
+}
+
+// newStatusCommand returns the `status` subcommand, querying the status API
+// of the plugin running on this node. It only takes the flags needed to find
+// the status socket, so it can be run without the plugin's configuration.
+func newStatusCommand() *cli.Command {
+	var kubeletPluginsDirectoryPath string
+	return &cli.Command{
+		Name:      "status",
+		Usage:     "Show the status of the plugin running on this node (all sections, or the given one).",
+		ArgsUsage: fmt.Sprintf("[%s|%s|%s|%s|%s|%s|%s]", statusSectionDevices, statusSectionLayout, statusSectionClaims, statusSectionMPS, statusSectionVfio, statusSectionFeatureGates, statusSectionFlags),
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "kubelet-plugins-directory-path",
+				Usage:       "Absolute path to the directory where kubelet stores plugin data.",
+				Value:       kubeletplugin.KubeletPluginsDir,
+				Destination: &kubeletPluginsDirectoryPath,
+				EnvVars:     []string{"KUBELET_PLUGINS_DIRECTORY_PATH"},
+			},
+		},
+		Action: func(c *cli.Context) error {
+			if c.Args().Len() > 1 {
+				return fmt.Errorf("at most one section can be given")
+			}
+			config := &Config{flags: &Flags{kubeletPluginsDirectoryPath: kubeletPluginsDirectoryPath}}
+			socket := filepath.Join(config.DriverPluginPath(), StatusSocketFileBasename)
+			return queryStatus(c.Context, socket, c.Args().First(), os.Stdout)
+		},
+	}
+}
+
+func queryStatus(ctx context.Context, socket, section string, out io.Writer) error {
+	client := &http.Client{
+		Timeout: statusRequestTimeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socket)
+			},
+		},
+	}
+
+	url := "http://localhost/status"
+	if section != "" {
+		url += "/" + section
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error querying status on %s: %w", socket, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("error querying status: %s: %s", resp.Status, body)
+	}
+	_, err = io.Copy(out, resp.Body)
+	return err
+}
@@ -0,0 +1,384 @@
+/*
+Copyright 2025 The HAMi Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/klog/v2"
+)
+
+// GPU telemetry: NVML is sampled periodically for all GPUs and MIG devices on
+// this node, and the samples are exported as metrics, once per device. The
+// claims (and pods) using a device are exported in an info metric to join
+// with, so that no separate exporter is needed to attribute GPU usage to
+// workloads, e.g.:
+//
+//	hami_dra_kubelet_plugin_gpu_claim_info
+//	  * on(uuid) group_left(device)
+//	  hami_dra_kubelet_plugin_gpu_memory_used_bytes
+
+// Labels of the device metrics. parent_uuid is only set for MIG devices.
+var telemetryLabels = []string{"uuid", "device", "parent_uuid"}
+
+var (
+	gpuUtilization = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "gpu",
+			Name:      "utilization_ratio",
+			Help:      "Fraction of the last sample period during which kernels were running on the GPU.",
+		},
+		telemetryLabels,
+	)
+	gpuMemoryUsed = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "gpu",
+			Name:      "memory_used_bytes",
+			Help:      "Used memory of the GPU or MIG device.",
+		},
+		telemetryLabels,
+	)
+	gpuMemoryTotal = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "gpu",
+			Name:      "memory_total_bytes",
+			Help:      "Total memory of the GPU or MIG device.",
+		},
+		telemetryLabels,
+	)
+	gpuTemperature = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "gpu",
+			Name:      "temperature_celsius",
+			Help:      "Temperature of the GPU.",
+		},
+		telemetryLabels,
+	)
+	gpuPower = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "gpu",
+			Name:      "power_usage_watts",
+			Help:      "Power usage of the GPU.",
+		},
+		telemetryLabels,
+	)
+	gpuProcesses = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "gpu",
+			Name:      "processes",
+			Help:      "Number of compute and graphics processes running on the GPU or MIG device.",
+		},
+		telemetryLabels,
+	)
+	gpuProcessMemoryUsed = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "gpu",
+			Name:      "process_memory_used_bytes",
+			Help:      "Memory used by a process on the GPU or MIG device, where NVML reports it.",
+		},
+		[]string{"uuid", "pid"},
+	)
+	gpuClaimInfo = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "gpu",
+			Name:      "claim_info",
+			Help:      "A GPU or MIG device used by a claim (and pod); always 1.",
+		},
+		[]string{"uuid", "claim_namespace", "claim_name", "pod"},
+	)
+	gpuTelemetrySampleDuration = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "gpu",
+			Name:      "telemetry_sample_duration_seconds",
+			Help:      "Duration of sampling the telemetry of all GPUs and MIG devices.",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 2, 16),
+		},
+	)
+)
+
+func init() {
+	metricsRegistry.MustRegister(
+		gpuUtilization,
+		gpuMemoryUsed,
+		gpuMemoryTotal,
+		gpuTemperature,
+		gpuPower,
+		gpuProcesses,
+		gpuProcessMemoryUsed,
+		gpuClaimInfo,
+		gpuTelemetrySampleDuration,
+	)
+}
+
+// NVML_VALUE_NOT_AVAILABLE, for unsigned values.
+const nvmlValueNotAvailable = ^uint64(0)
+
+// DeviceTelemetry is a sample of a GPU or MIG device. Values which are not
+// supported by the device (e.g. utilization of a MIG device) are nil.
+type DeviceTelemetry struct {
+	UUID string
+	// Set for MIG devices only.
+	ParentUUID string
+	// Percent.
+	Utilization *uint32
+	MemoryUsed  *uint64
+	MemoryTotal *uint64
+	// Degrees Celsius.
+	Temperature *uint32
+	// Milliwatts.
+	Power     *uint32
+	Processes *int
+	// Bytes, by PID; only processes for which NVML reports it.
+	ProcessMemoryUsed map[uint32]uint64
+}
+
+// telemetryConsumer is a claim (and pod) using a device.
+type telemetryConsumer struct {
+	claimNamespace string
+	claimName      string
+	pod            string
+}
+
+// sampleTelemetry samples all GPUs and their MIG devices.
+func (l deviceLib) sampleTelemetry() ([]*DeviceTelemetry, error) {
+	shutdown, ret := l.ensureNVML()
+	if ret != nvml.SUCCESS {
+		return nil, fmt.Errorf("ensureNVML failed: %w", ret)
+	}
+	defer shutdown()
+
+	count, ret := l.nvmllib.DeviceGetCount()
+	if ret != nvml.SUCCESS {
+		return nil, fmt.Errorf("error getting device count: %v", ret)
+	}
+
+	var samples []*DeviceTelemetry
+	for i := 0; i < count; i++ {
+		gpu, ret := l.nvmllib.DeviceGetHandleByIndex(i)
+		if ret != nvml.SUCCESS {
+			klog.V(4).Infof("Telemetry: unable to get handle for GPU %d: %v", i, ret)
+			continue
+		}
+		uuid, ret := gpu.GetUUID()
+		if ret != nvml.SUCCESS {
+			klog.V(4).Infof("Telemetry: unable to get UUID of GPU %d: %v", i, ret)
+			continue
+		}
+		samples = append(samples, sampleNvmlDevice(uuid, gpu))
+
+		current, _, ret := gpu.GetMigMode()
+		if ret != nvml.SUCCESS || current != nvml.DEVICE_MIG_ENABLE {
+			continue
+		}
+		err := walkMigDevices(gpu, func(j int, mig nvml.Device) error {
+			migUUID, ret := mig.GetUUID()
+			if ret != nvml.SUCCESS {
+				klog.V(4).Infof("Telemetry: unable to get UUID of MIG device %d on GPU %s: %v", j, uuid, ret)
+				return nil
+			}
+			sample := sampleNvmlDevice(migUUID, mig)
+			sample.ParentUUID = uuid
+			samples = append(samples, sample)
+			return nil
+		})
+		if err != nil {
+			klog.V(4).Infof("Telemetry: unable to walk MIG devices of GPU %s: %v", uuid, err)
+		}
+	}
+	return samples, nil
+}
+
+// sampleNvmlDevice samples a GPU or MIG device. Queries which fail (usually
+// as not supported) are skipped.
+func sampleNvmlDevice(uuid string, device nvml.Device) *DeviceTelemetry {
+	t := &DeviceTelemetry{UUID: uuid}
+
+	if utilization, ret := device.GetUtilizationRates(); ret == nvml.SUCCESS {
+		t.Utilization = &utilization.Gpu
+	}
+	if memory, ret := device.GetMemoryInfo(); ret == nvml.SUCCESS {
+		t.MemoryUsed = &memory.Used
+		t.MemoryTotal = &memory.Total
+	}
+	if temperature, ret := device.GetTemperature(nvml.TEMPERATURE_GPU); ret == nvml.SUCCESS {
+		t.Temperature = &temperature
+	}
+	if power, ret := device.GetPowerUsage(); ret == nvml.SUCCESS {
+		t.Power = &power
+	}
+
+	cprocs, cret := device.GetComputeRunningProcesses()
+	gprocs, gret := device.GetGraphicsRunningProcesses()
+	if cret == nvml.SUCCESS || gret == nvml.SUCCESS {
+		pids := make(map[uint32]struct{})
+		t.ProcessMemoryUsed = make(map[uint32]uint64)
+		for _, p := range append(cprocs, gprocs...) {
+			pids[p.Pid] = struct{}{}
+			// A process may be both a compute and a graphics process:
+			// do not count its memory twice.
+			if p.UsedGpuMemory != nvmlValueNotAvailable {
+				t.ProcessMemoryUsed[p.Pid] = max(t.ProcessMemoryUsed[p.Pid], p.UsedGpuMemory)
+			}
+		}
+		processes := len(pids)
+		t.Processes = &processes
+	}
+	return t
+}
+
+// telemetryConsumers returns the claims (and pods) using each device, by
+// device UUID. Only completely prepared claims are considered.
+func telemetryConsumers(cp *Checkpoint) map[string][]telemetryConsumer {
+	consumers := make(map[string][]telemetryConsumer)
+	for _, claim := range cp.V2.PreparedClaims {
+		if claim.CheckpointState != ClaimCheckpointStatePrepareCompleted {
+			continue
+		}
+
+		var pods []string
+		for _, consumer := range claim.Status.ReservedFor {
+			if consumer.APIGroup == "" && consumer.Resource == "pods" {
+				pods = append(pods, consumer.Name)
+			}
+		}
+		if len(pods) == 0 {
+			pods = []string{""}
+		}
+
+		for _, group := range claim.PreparedDevices {
+			for _, device := range group.Devices {
+				var uuid string
+				switch device.Type() {
+				case HAMiGpuDeviceType:
+					uuid = device.HAMiGpu.Info.UUID
+				case GpuDeviceType:
+					uuid = device.Gpu.Info.UUID
+				case PreparedMigDeviceType:
+					uuid = device.Mig.Concrete.MigUUID
+				default:
+					continue
+				}
+				for _, pod := range pods {
+					consumers[uuid] = append(consumers[uuid], telemetryConsumer{
+						claimNamespace: claim.Namespace,
+						claimName:      claim.Name,
+						pod:            pod,
+					})
+				}
+			}
+		}
+	}
+	return consumers
+}
+
+// UpdateTelemetryMetrics samples all GPUs and MIG devices and replaces the
+// telemetry metrics.
+func (s *DeviceState) UpdateTelemetryMetrics(ctx context.Context) error {
+	t0 := time.Now()
+
+	cp, err := s.getCheckpoint(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to get checkpoint: %w", err)
+	}
+	consumers := telemetryConsumers(cp)
+
+	names := make(map[string]DeviceName)
+	s.Lock()
+	for name, device := range s.allocatable {
+		if device.Type() == MigDynamicDeviceType || device.Type() == VfioDeviceType {
+			continue
+		}
+		names[device.UUID()] = name
+	}
+	s.Unlock()
+
+	samples, err := s.nvdevlib.sampleTelemetry()
+	if err != nil {
+		return fmt.Errorf("error sampling GPU telemetry: %w", err)
+	}
+
+	for _, vec := range []*prometheus.GaugeVec{gpuUtilization, gpuMemoryUsed, gpuMemoryTotal, gpuTemperature, gpuPower, gpuProcesses, gpuProcessMemoryUsed, gpuClaimInfo} {
+		vec.Reset()
+	}
+	for _, sample := range samples {
+		labels := prometheus.Labels{
+			"uuid":        sample.UUID,
+			"device":      string(names[sample.UUID]),
+			"parent_uuid": sample.ParentUUID,
+		}
+		if sample.Utilization != nil {
+			gpuUtilization.With(labels).Set(float64(*sample.Utilization) / 100)
+		}
+		if sample.MemoryUsed != nil {
+			gpuMemoryUsed.With(labels).Set(float64(*sample.MemoryUsed))
+			gpuMemoryTotal.With(labels).Set(float64(*sample.MemoryTotal))
+		}
+		if sample.Temperature != nil {
+			gpuTemperature.With(labels).Set(float64(*sample.Temperature))
+		}
+		if sample.Power != nil {
+			gpuPower.With(labels).Set(float64(*sample.Power) / 1000)
+		}
+		if sample.Processes != nil {
+			gpuProcesses.With(labels).Set(float64(*sample.Processes))
+		}
+		for pid, used := range sample.ProcessMemoryUsed {
+			gpuProcessMemoryUsed.WithLabelValues(sample.UUID, strconv.FormatUint(uint64(pid), 10)).Set(float64(used))
+		}
+		for _, u := range consumers[sample.UUID] {
+			gpuClaimInfo.WithLabelValues(sample.UUID, u.claimNamespace, u.claimName, u.pod).Set(1)
+		}
+	}
+
+	gpuTelemetrySampleDuration.Observe(time.Since(t0).Seconds())
+	klog.V(6).Infof("t_gpu_telemetry %.3f s (%d device(s))", time.Since(t0).Seconds(), len(samples))
+	return nil
+}
+
+// gpuTelemetryLoop() periodically runs UpdateTelemetryMetrics() until the
+// context is canceled.
+func (d *driver) gpuTelemetryLoop(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	defer backgroundLoops.stop(loopGPUTelemetry)
+
+	for {
+		backgroundLoops.beat(loopGPUTelemetry, 2*interval+time.Minute)
+		if err := d.state.UpdateTelemetryMetrics(ctx); err != nil {
+			klog.Warningf("GPU telemetry: %s", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
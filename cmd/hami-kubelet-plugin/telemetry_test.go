@@ -0,0 +1,94 @@
+/*
+Copyright 2025 The HAMi Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	resourceapi "k8s.io/api/resource/v1"
+)
+
+func TestTelemetryConsumers(t *testing.T) {
+	gpu := func(uuid string) PreparedDevice {
+		return PreparedDevice{Gpu: &PreparedGpu{Info: &GpuInfo{UUID: uuid}}}
+	}
+	mig := func(uuid string) PreparedDevice {
+		return PreparedDevice{Mig: &PreparedMigDevice{Concrete: &MigLiveTuple{MigUUID: uuid}}}
+	}
+	pod := func(name string) resourceapi.ResourceClaimConsumerReference {
+		return resourceapi.ResourceClaimConsumerReference{Resource: "pods", Name: name}
+	}
+	claim := func(name string, state ClaimCheckpointState, consumers []resourceapi.ResourceClaimConsumerReference, devices ...PreparedDevice) PreparedClaim {
+		return PreparedClaim{
+			CheckpointState: state,
+			Namespace:       "ns",
+			Name:            name,
+			Status:          resourceapi.ResourceClaimStatus{ReservedFor: consumers},
+			PreparedDevices: PreparedDevices{{Devices: devices}},
+		}
+	}
+
+	for _, tc := range []struct {
+		name     string
+		claims   PreparedClaimsByUIDV2
+		expected map[string][]telemetryConsumer
+	}{
+		{
+			name:     "no claims",
+			expected: map[string][]telemetryConsumer{},
+		},
+		{
+			name: "partially prepared claims are skipped",
+			claims: PreparedClaimsByUIDV2{
+				"uid-1": claim("claim-1", ClaimCheckpointStatePrepareStarted, nil, gpu("GPU-0")),
+			},
+			expected: map[string][]telemetryConsumer{},
+		},
+		{
+			name: "claim without pod",
+			claims: PreparedClaimsByUIDV2{
+				"uid-1": claim("claim-1", ClaimCheckpointStatePrepareCompleted, nil, gpu("GPU-0"), mig("MIG-1")),
+			},
+			expected: map[string][]telemetryConsumer{
+				"GPU-0": {{claimNamespace: "ns", claimName: "claim-1"}},
+				"MIG-1": {{claimNamespace: "ns", claimName: "claim-1"}},
+			},
+		},
+		{
+			name: "one entry per pod",
+			claims: PreparedClaimsByUIDV2{
+				"uid-1": claim("claim-1", ClaimCheckpointStatePrepareCompleted, []resourceapi.ResourceClaimConsumerReference{
+					pod("pod-a"),
+					pod("pod-b"),
+					{APIGroup: "batch", Resource: "jobs", Name: "job"},
+				}, gpu("GPU-0")),
+			},
+			expected: map[string][]telemetryConsumer{
+				"GPU-0": {
+					{claimNamespace: "ns", claimName: "claim-1", pod: "pod-a"},
+					{claimNamespace: "ns", claimName: "claim-1", pod: "pod-b"},
+				},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cp := &Checkpoint{V2: &CheckpointV2{PreparedClaims: tc.claims}}
+			require.Equal(t, tc.expected, telemetryConsumers(cp))
+		})
+	}
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2025 The HAMi Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+)
+
+const tracerName = "github.com/Project-HAMi/k8s-dra-driver/cmd/hami-kubelet-plugin"
+
+// Span attributes.
+const (
+	attrClaimUID       = attribute.Key("claim.uid")
+	attrClaimNamespace = attribute.Key("claim.namespace")
+	attrClaimName      = attribute.Key("claim.name")
+	attrDevice         = attribute.Key("device")
+	attrLock           = attribute.Key("lock")
+)
+
+type claimUIDKey struct{}
+
+// withClaimUID returns a context carrying the claim UID: spans started from it
+// (and from derived contexts) get the claim UID as attribute.
+func withClaimUID(ctx context.Context, uid types.UID) context.Context {
+	return context.WithValue(ctx, claimUIDKey{}, uid)
+}
+
+// startSpan starts a span, with the claim UID of the context (if any) as
+// attribute. Spans go to the global tracer provider: they are dropped unless
+// tracing is set up with setupTracing().
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	if uid, ok := ctx.Value(claimUIDKey{}).(types.UID); ok {
+		attrs = append(attrs, attrClaimUID.String(string(uid)))
+	}
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// endSpan ends a span, recording err (if not nil) as its error status.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// setupTracing installs a global tracer provider exporting spans via OTLP
+// (gRPC) to the configured endpoint, and returns a function flushing and
+// shutting it down. Tracing is disabled (and the function a no-op) if no
+// endpoint is configured.
+func setupTracing(ctx context.Context, config *Config) (func(context.Context) error, error) {
+	endpoint := config.flags.tracingEndpoint
+	if endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracegrpc.New(ctx, otlptracegrpc.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, fmt.Errorf("error creating OTLP trace exporter: %w", err)
+	}
+
+	res := resource.NewSchemaless(
+		semconv.ServiceName(eventSourceComponent),
+		semconv.HostName(config.flags.nodeName),
+	)
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.flags.tracingSamplingRatio))),
+	)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	klog.Infof("Exporting traces to %s (sampling ratio: %v)", endpoint, config.flags.tracingSamplingRatio)
+	return tp.Shutdown, nil
+}
@@ -0,0 +1,175 @@
+/*
+Copyright 2025 The HAMi Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/NVIDIA/k8s-dra-driver-gpu/pkg/flock"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/dynamic-resource-allocation/kubeletplugin"
+	"k8s.io/kubernetes/pkg/kubelet/checkpointmanager"
+)
+
+func setupTestTracing(t *testing.T) *tracetest.InMemoryExporter {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	t.Cleanup(func() {
+		_ = tp.Shutdown(context.Background())
+		otel.SetTracerProvider(previous)
+	})
+	return exporter
+}
+
+func spanAttribute(span tracetest.SpanStub, key attribute.Key) (attribute.Value, bool) {
+	for _, kv := range span.Attributes {
+		if kv.Key == key {
+			return kv.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+func TestSpansCarryClaimUID(t *testing.T) {
+	exporter := setupTestTracing(t)
+
+	ctx, claimSpan := startSpan(withClaimUID(context.Background(), types.UID("uid-1")), "PrepareClaim")
+	_, lockSpan := startSpan(ctx, "AcquireLock", attrLock.String("checkpoint"))
+	endSpan(lockSpan, nil)
+	endSpan(claimSpan, nil)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 2)
+	for _, span := range spans {
+		uid, ok := spanAttribute(span, attrClaimUID)
+		require.True(t, ok, "span %s has no claim UID", span.Name)
+		require.Equal(t, "uid-1", uid.AsString())
+	}
+
+	lock := spans[0]
+	require.Equal(t, "AcquireLock", lock.Name)
+	require.Equal(t, spans[1].SpanContext.SpanID(), lock.Parent.SpanID())
+	value, ok := spanAttribute(lock, attrLock)
+	require.True(t, ok)
+	require.Equal(t, "checkpoint", value.AsString())
+}
+
+func TestSpanWithoutClaimUID(t *testing.T) {
+	exporter := setupTestTracing(t)
+
+	_, span := startSpan(context.Background(), "PublishResources")
+	endSpan(span, nil)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	_, ok := spanAttribute(spans[0], attrClaimUID)
+	require.False(t, ok)
+	require.Equal(t, codes.Unset, spans[0].Status.Code)
+}
+
+func TestEndSpanRecordsError(t *testing.T) {
+	exporter := setupTestTracing(t)
+
+	_, span := startSpan(context.Background(), "GetCheckpoint")
+	endSpan(span, errors.New("checkpoint is corrupted"))
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	require.Equal(t, codes.Error, spans[0].Status.Code)
+	require.Equal(t, "checkpoint is corrupted", spans[0].Status.Description)
+	require.Len(t, spans[0].Events, 1)
+	require.Equal(t, "exception", spans[0].Events[0].Name)
+}
+
+func TestSetupTracingDisabled(t *testing.T) {
+	shutdown, err := setupTracing(context.Background(), &Config{flags: &Flags{}})
+	require.NoError(t, err)
+	require.NoError(t, shutdown(context.Background()))
+}
+
+// newTestDriver returns a driver without devices, and without checkpoint.
+func newTestDriver(t *testing.T) *driver {
+	dir := t.TempDir()
+	checkpointManager, err := checkpointmanager.NewCheckpointManager(dir)
+	require.NoError(t, err)
+	state := &DeviceState{
+		config:            &Config{flags: &Flags{}},
+		checkpointManager: checkpointManager,
+		cplock:            flock.NewFlock(filepath.Join(dir, "cp.lock")),
+	}
+	return &driver{
+		state:  state,
+		pulock: flock.NewFlock(filepath.Join(dir, "pu.lock")),
+	}
+}
+
+// Unprepare fails reading the (missing) checkpoint: the error is recorded
+// along the span path.
+func TestUnprepareIsTraced(t *testing.T) {
+	d := newTestDriver(t)
+	exporter := setupTestTracing(t)
+
+	claimRef := kubeletplugin.NamespacedObject{
+		NamespacedName: types.NamespacedName{Namespace: "default", Name: "claim-1"},
+		UID:            types.UID("uid-1"),
+	}
+	results, err := d.UnprepareResourceClaims(context.Background(), []kubeletplugin.NamespacedObject{claimRef})
+	require.NoError(t, err)
+	require.Error(t, results[claimRef.UID])
+
+	spans := exporter.GetSpans()
+	byName := make(map[string]tracetest.SpanStub)
+	for _, span := range spans {
+		byName[span.Name] = span
+	}
+
+	root, ok := byName["UnprepareResourceClaims"]
+	require.True(t, ok)
+	claim, ok := byName["UnprepareClaim"]
+	require.True(t, ok)
+	require.Equal(t, root.SpanContext.SpanID(), claim.Parent.SpanID())
+	name, ok := spanAttribute(claim, attrClaimName)
+	require.True(t, ok)
+	require.Equal(t, "claim-1", name.AsString())
+	require.Equal(t, codes.Error, claim.Status.Code)
+
+	// Everything below the claim span carries the claim UID.
+	for _, span := range spans {
+		if span.Name == "UnprepareResourceClaims" {
+			continue
+		}
+		uid, ok := spanAttribute(span, attrClaimUID)
+		require.True(t, ok, "span %s has no claim UID", span.Name)
+		require.Equal(t, "uid-1", uid.AsString())
+	}
+
+	cp, ok := byName["GetCheckpoint"]
+	require.True(t, ok)
+	require.Equal(t, claim.SpanContext.SpanID(), cp.Parent.SpanID())
+	require.Equal(t, codes.Error, cp.Status.Code)
+}
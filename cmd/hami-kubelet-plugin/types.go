@@ -20,7 +20,6 @@ import (
 	"fmt"
 
 	resourcev1 "k8s.io/api/resource/v1"
-	"k8s.io/dynamic-resource-allocation/kubeletplugin"
 )
 
 const (
@@ -52,19 +51,3 @@ func ResourceClaimToString(rc *resourcev1.ResourceClaim) string {
 func PreparedClaimToString(pc *PreparedClaim, uid string) string {
 	return fmt.Sprintf("%s/%s:%s", pc.Namespace, pc.Name, uid)
 }
-
-func ClaimsToStrings(claims []*resourcev1.ResourceClaim) []string {
-	var results []string
-	for _, c := range claims {
-		results = append(results, ResourceClaimToString(c))
-	}
-	return results
-}
-
-func ClaimRefsToStrings(claimRefs []kubeletplugin.NamespacedObject) []string {
-	var results []string
-	for _, r := range claimRefs {
-		results = append(results, r.String())
-	}
-	return results
-}
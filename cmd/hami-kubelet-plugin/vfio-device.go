@@ -155,10 +155,10 @@ func (vm *VfioPciManager) WaitForGPUFree(ctx context.Context, info *VfioDeviceIn
 				if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
 					return nil
 				}
-				klog.Errorf("Unexpected error checking if gpu device %q is free: %v", info.pcieBusID, err)
+				klog.FromContext(ctx).Error(err, "Unexpected error checking if GPU is free", logKeyDevice, info.CanonicalName(), "pciBusID", info.pcieBusID)
 				continue
 			}
-			klog.Infof("gpu device %q has open fds by process(es): %s", info.pcieBusID, string(out))
+			klog.FromContext(ctx).Info("GPU has open file descriptors", logKeyDevice, info.CanonicalName(), "pciBusID", info.pcieBusID, "processes", string(out))
 		}
 	}
 }
@@ -192,6 +192,10 @@ func (vm *VfioPciManager) Configure(ctx context.Context, info *VfioDeviceInfo) e
 	if !vm.nvidiaEnabled || driver != nvidiaDriver {
 		return fmt.Errorf("gpu is bound to %q driver, expected %q or %q", driver, vm.driver, nvidiaDriver)
 	}
+	// Do not wait for the GPU to be free either.
+	if vm.nvlib.dryRunMutation(ctx, auditOpChangeDriver, info.pcieBusID, driver, vm.driver) {
+		return nil
+	}
 	err = vm.WaitForGPUFree(ctx, info)
 	if err != nil {
 		return err
@@ -200,7 +204,7 @@ func (vm *VfioPciManager) Configure(ctx context.Context, info *VfioDeviceInfo) e
 	if err != nil {
 		return err
 	}
-	err = vm.changeDriver(info.pcieBusID, vm.driver)
+	err = vm.changeDriver(ctx, info.pcieBusID, driver, vm.driver)
 	if err != nil {
 		return err
 	}
@@ -224,7 +228,10 @@ func (vm *VfioPciManager) Unconfigure(ctx context.Context, info *VfioDeviceInfo)
 	if driver == nvidiaDriver {
 		return nil
 	}
-	err = vm.changeDriver(info.pcieBusID, nvidiaDriver)
+	if vm.nvlib.dryRunMutation(ctx, auditOpChangeDriver, info.pcieBusID, driver, nvidiaDriver) {
+		return nil
+	}
+	err = vm.changeDriver(ctx, info.pcieBusID, driver, nvidiaDriver)
 	if err != nil {
 		return err
 	}
@@ -240,31 +247,37 @@ func getDriver(pciDevicesRoot, pciAddress string) (string, error) {
 	return driver, nil
 }
 
-func (vm *VfioPciManager) changeDriver(pciAddress, driver string) error {
-	err := vm.unbindFromDriver(pciAddress)
+// changeDriver rebinds the device from its current driver to the given one.
+func (vm *VfioPciManager) changeDriver(ctx context.Context, pciAddress, current, driver string) (rerr error) {
+	t0 := time.Now()
+	defer func() {
+		vm.nvlib.auditLog.mutation(ctx, auditOpChangeDriver, pciAddress, current, driver, t0, rerr)
+	}()
+
+	err := vm.unbindFromDriver(ctx, pciAddress)
 	if err != nil {
 		return err
 	}
-	err = vm.bindToDriver(pciAddress, driver)
+	err = vm.bindToDriver(ctx, pciAddress, driver)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (vm *VfioPciManager) unbindFromDriver(pciAddress string) error {
+func (vm *VfioPciManager) unbindFromDriver(ctx context.Context, pciAddress string) error {
 	out, err := execCommand(unbindFromDriverScript, []string{pciAddress}) //nolint:gosec
 	if err != nil {
-		klog.Errorf("Attempting to unbind %s from its driver failed; stdout: %s, err: %v", pciAddress, string(out), err)
+		klog.FromContext(ctx).Error(err, "Unbinding from driver failed", "pciBusID", pciAddress, "output", string(out))
 		return err
 	}
 	return nil
 }
 
-func (vm *VfioPciManager) bindToDriver(pciAddress, driver string) error {
+func (vm *VfioPciManager) bindToDriver(ctx context.Context, pciAddress, driver string) error {
 	out, err := execCommand(bindToDriverScript, []string{pciAddress, driver}) //nolint:gosec
 	if err != nil {
-		klog.Errorf("Attempting to bind %s to %s driver failed; stdout: %s, err: %v", pciAddress, driver, string(out), err)
+		klog.FromContext(ctx).Error(err, "Binding to driver failed", "pciBusID", pciAddress, "driver", driver, "output", string(out))
 		return err
 	}
 	return nil
@@ -18,53 +18,72 @@
 package common
 
 import (
-	"net"
+	"fmt"
+	"io"
 	"os"
 	"os/signal"
-	"runtime"
+	"path/filepath"
+	"runtime/pprof"
 	"syscall"
 
 	"k8s.io/klog/v2"
 )
 
-const dumpPath = "/tmp/goroutine-stacks.dump"
+// GoroutineDumpFileBasename is the basename of the file (in the dump
+// directory) SIGUSR2 dumps goroutine stacks to.
+const GoroutineDumpFileBasename = "goroutine-stacks.dump"
+
+// WriteGoroutineStacks writes the stack traces of all goroutines to w, in the
+// format used for an unrecovered panic. Other than runtime.Stack() with a
+// fixed buffer, this never truncates.
+func WriteGoroutineStacks(w io.Writer) error {
+	return pprof.Lookup("goroutine").WriteTo(w, 2)
+}
 
 // Set up SIGUSR2 handler: if triggered, acquire stack traces for all goroutines
-// in this process. Dump to file, and fall back to emitting to stderr if file
-// output didn't work.
-func StartDebugSignalHandlers() {
+// in this process. Dump to a file in dumpDir, and fall back to emitting to
+// stderr if file output didn't work.
+func StartDebugSignalHandlers(dumpDir string) {
+	dumpPath := filepath.Join(dumpDir, GoroutineDumpFileBasename)
 	go func() {
 		c := make(chan os.Signal, 1)
 		signal.Notify(c, syscall.SIGUSR2)
 		for range c {
-			// Pre-allocate a large-ish buffer for Stack() to write to (output
-			// is truncated if buffer isn't big enough).
-			buf := make([]byte, 1024*1024*2)
-			written := runtime.Stack(buf, true)
-
-			// Try to detect truncated output. Checking for `written == size`
-			// may not be sufficient. For example, if the dump routine finds
-			// that there isn't enough space for the next full record (full
-			// goroutine stack, or full line), it might stop writing rather than
-			// writing a partial line. Just add a warning to the file if we got
-			// close-ish to the buffer boundary.
-			if written > len(buf)-5000 {
-				suffix := "\n\nwarning: runtime.Stack() output may be truncated"
-				buf = append(buf, suffix...)
-				written += len(suffix)
-			}
-
-			err := os.WriteFile(dumpPath, buf[:written], 0666)
+			err := writeFileAtomically(dumpPath, WriteGoroutineStacks)
 			if err == nil {
 				klog.Infof("Wrote: %s", dumpPath)
 				continue
 			}
 
 			klog.Errorf("Could not write %s: %s", dumpPath, err)
-			chunks := net.Buffers{[]byte("\n"), buf[:written], []byte("\n")}
-			_, _ = chunks.WriteTo(os.Stderr)
+			fmt.Fprintln(os.Stderr)
+			_ = WriteGoroutineStacks(os.Stderr)
 		}
 	}()
 
 	klog.Infof("Started debug signal handler(s)")
 }
+
+// writeFileAtomically writes a file via a temporary file in the same
+// directory: readers never see a partially written dump.
+func writeFileAtomically(path string, write func(io.Writer) error) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(f.Name())
+	}()
+
+	if err := write(f); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("error writing dump: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(f.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
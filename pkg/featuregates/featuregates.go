@@ -49,6 +49,10 @@ const (
 	// NVMLDeviceHealthCheck allows Device Health Checking using NVML.
 	NVMLDeviceHealthCheck featuregate.Feature = "NVMLDeviceHealthCheck"
 
+	// DeviceHealthTaints announces unhealthy devices with DRA device taints
+	// instead of removing them from the ResourceSlice.
+	DeviceHealthTaints featuregate.Feature = "DeviceHealthTaints"
+
 	// Enable dynamic MIG device management.
 	DynamicMIG featuregate.Feature = "DynamicMIG"
 
@@ -113,6 +117,13 @@ var defaultFeatureGates = map[featuregate.Feature]featuregate.VersionedSpecs{
 			Version:    version.MajorMinor(25, 12),
 		},
 	},
+	DeviceHealthTaints: {
+		{
+			Default:    false,
+			PreRelease: featuregate.Alpha,
+			Version:    version.MajorMinor(25, 12),
+		},
+	},
 	ComputeDomainCliques: {
 		{
 			Default:    false,
@@ -206,8 +217,8 @@ func ValidateFeatureGates() error {
 		return fmt.Errorf("feature gate %s is currently mutually exclusive with %s", DynamicMIG, PassthroughSupport)
 	}
 
-	if Enabled(DynamicMIG) && Enabled(NVMLDeviceHealthCheck) {
-		return fmt.Errorf("feature gate %s is currently mutually exclusive with %s", DynamicMIG, NVMLDeviceHealthCheck)
+	if Enabled(DeviceHealthTaints) && !Enabled(NVMLDeviceHealthCheck) {
+		return fmt.Errorf("feature gate %s requires %s to also be enabled", DeviceHealthTaints, NVMLDeviceHealthCheck)
 	}
 
 	if Enabled(DynamicMIG) && Enabled(MPSSupport) {